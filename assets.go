@@ -0,0 +1,129 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const immutableCache = "public, max-age=31536000, immutable"
+
+type staticAsset struct {
+	contentType string
+	etag        string
+	content     []byte
+	gzipped     []byte
+}
+
+var staticAssets = withThemes(map[string]*staticAsset{
+	"slides.js":  newStaticAsset("application/javascript", slidesJS),
+	"remote.js":  newStaticAsset("application/javascript", remoteJS),
+	"play.js":    newStaticAsset("application/javascript", playJS),
+	"notes.js":   newStaticAsset("application/javascript", notesJS),
+	"toc.js":     newStaticAsset("application/javascript", tocJS),
+	"styles.css": newStaticAsset("text/css; charset=utf-8", stylesCSS),
+	"print.css":  newStaticAsset("text/css; charset=utf-8", printCSS),
+})
+
+// staticVersion changes whenever any static asset does. It is appended to
+// asset URLs so they can be cached indefinitely.
+var staticVersion = contentHash(append([]string{slidesJS, remoteJS, playJS, notesJS, tocJS, stylesCSS, printCSS},
+	themeStylesheets()...)...)
+
+// withThemes adds the stylesheet of every built-in theme to assets.
+func withThemes(assets map[string]*staticAsset) map[string]*staticAsset {
+	for name, css := range builtinThemes {
+		assets["themes/"+name+".css"] = newStaticAsset("text/css; charset=utf-8", css)
+	}
+	return assets
+}
+
+// themeStylesheets returns the stylesheets of the built-in themes, ordered by
+// theme name.
+func themeStylesheets() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stylesheets := make([]string, len(names))
+	for i, name := range names {
+		stylesheets[i] = builtinThemes[name]
+	}
+	return stylesheets
+}
+
+func newStaticAsset(contentType, content string) *staticAsset {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(content))
+	gz.Close()
+
+	return &staticAsset{
+		contentType: contentType,
+		etag:        `"` + contentHash(content) + `"`,
+		content:     []byte(content),
+		gzipped:     buf.Bytes(),
+	}
+}
+
+func (a *staticAsset) serve(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Content-Type", a.contentType)
+	h.Set("Vary", "Accept-Encoding")
+	setCacheControl(w, r, staticVersion)
+
+	content, etag := a.content, a.etag
+	if a.gzipped != nil && acceptsGzip(r) {
+		h.Set("Content-Encoding", "gzip")
+		content, etag = a.gzipped, strings.TrimSuffix(a.etag, `"`)+`-gzip"`
+	}
+	h.Set("ETag", etag)
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+// setCacheControl lets clients cache a response forever when it was requested
+// with the current version, and makes them revalidate it otherwise.
+func setCacheControl(w http.ResponseWriter, r *http.Request, version string) {
+	if version != "" && r.URL.Query().Get("v") == version {
+		w.Header().Set("Cache-Control", immutableCache)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(enc, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") && strings.Trim(q[2:], "0.") == "" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func contentHash(contents ...string) string {
+	h := sha256.New()
+	for _, c := range contents {
+		h.Write([]byte(c))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:8])
+}
@@ -0,0 +1,193 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"html/template"
+	"net/url"
+	"sync"
+
+	"golang.org/x/tools/present"
+)
+
+// baseTmpl holds the parsed slide templates. The functions defined here are
+// placeholders, rebound for every slide or view ID by deckCache.template.
+var baseTmpl = template.Must(parseBaseTemplate())
+
+var decks = &deckCache{
+	decks:    make(map[string]*deck),
+	versions: make(map[string]int),
+	tmpls:    make(map[string]*template.Template),
+}
+
+func parseBaseTemplate() (*template.Template, error) {
+	tmpl := present.Template()
+	tmpl.Funcs(slideFuncs("", ""))
+
+	if _, err := tmpl.New("action").Parse(actionTmpl); err != nil {
+		return nil, err
+	}
+
+	if _, err := tmpl.New("slides").Parse(slidesTmpl); err != nil {
+		return nil, err
+	}
+
+	if _, err := tmpl.New("handout").Parse(handoutTmpl); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+func slideFuncs(slideId, slideIdParam string) template.FuncMap {
+	// Resource URLs always use the view ID, so that pages rendered for
+	// presenters don't spread their ID.
+	viewId := slideIdParam
+	if slideId == slideIdParam {
+		_, viewId = index.getIdPair(slideId)
+	}
+	resPrefix := *pathPrefix + "/res/" + viewId + "/"
+
+	return template.FuncMap{"playable": func(c present.Code) bool { return c.Play && *playEnabled },
+		"rSlideId": func() string {
+			return slideIdParam
+		},
+		"pathPrefix": func() string {
+			return *pathPrefix
+		},
+		"staticVersion": func() string {
+			return staticVersion
+		},
+		"deckVersion": func() string {
+			return deckVersion(slideId)
+		},
+		"resURL": func(ref string) string {
+			return resourceURL(resPrefix, ref, deckVersion(slideId))
+		},
+		"deckStyle": func() template.CSS {
+			d, err := decks.deck(slideId)
+			if err != nil {
+				return ""
+			}
+			return d.meta.fontCSS(resPrefix, deckVersion(slideId))
+		},
+		"layoutClass": func() string {
+			d, err := decks.deck(slideId)
+			if err != nil {
+				return "layout-widescreen"
+			}
+			return d.meta.layoutClass()
+		},
+		"themeStyles": func() []string {
+			d, err := decks.deck(slideId)
+			if err != nil {
+				return nil
+			}
+
+			var urls []string
+			if d.meta.Theme != "" {
+				urls = append(urls, *pathPrefix+"/static/themes/"+d.meta.Theme+".css?v="+staticVersion)
+			}
+			if d.themeCSS {
+				urls = append(urls, resourceURL(resPrefix, deckThemeFile, deckVersion(slideId)))
+			}
+			return urls
+		},
+		"toc": func() []tocEntry {
+			d, err := decks.deck(slideId)
+			if err != nil {
+				return nil
+			}
+			return d.toc()
+		},
+		"userRole": func() string {
+			if slideId == slideIdParam {
+				return "p"
+			} else {
+				return "v"
+			}
+		}}
+}
+
+// resourceURL returns the URL of ref under resPrefix if it is relative to the
+// deck, adding version so that it can be cached. Other references, such as
+// absolute URLs, paths on the server or fragments, are returned as they are.
+func resourceURL(resPrefix, ref, version string) string {
+	u, err := url.Parse(ref)
+	if err != nil || !deckRelative(u) {
+		return ref
+	}
+
+	u.Path = resPrefix + u.Path
+	query := u.Query()
+	query.Set("v", version)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// deckCache caches parsed decks by slide ID and bound templates by the slide
+// or view ID they are requested with.
+type deckCache struct {
+	sync.RWMutex
+	decks    map[string]*deck
+	versions map[string]int
+	tmpls    map[string]*template.Template
+}
+
+func (c *deckCache) deck(slideId string) (*deck, error) {
+	c.RLock()
+	d, ok := c.decks[slideId]
+	version := c.versions[slideId]
+	c.RUnlock()
+
+	if ok {
+		return d, nil
+	}
+
+	d, err := loadDeck(slideId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	// Don't cache a deck that was replaced while it was being loaded.
+	if c.versions[slideId] == version {
+		c.decks[slideId] = d
+	}
+
+	return d, nil
+}
+
+func (c *deckCache) template(slideId, slideIdParam string) (*template.Template, error) {
+	c.RLock()
+	tmpl, ok := c.tmpls[slideIdParam]
+	c.RUnlock()
+
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := baseTmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(slideFuncs(slideId, slideIdParam))
+
+	c.Lock()
+	defer c.Unlock()
+	c.tmpls[slideIdParam] = tmpl
+
+	return tmpl, nil
+}
+
+// invalidate drops the loaded deck for slideId, e.g. after it is replaced.
+func (c *deckCache) invalidate(slideId string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.decks, slideId)
+	c.versions[slideId]++
+}
@@ -0,0 +1,187 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const benchSlide = `Benchmark Deck
+A deck for benchmarks
+
+* First
+
+Some *bold* and _italic_ text.
+
+- one
+- two
+- three
+
+* Second
+
+	fmt.Println("Hello")
+`
+
+// useSlidesDir points slidesDir at a temporary directory for the test.
+func useSlidesDir(t testing.TB) string {
+	dir := t.TempDir()
+	old := *slidesDir
+	*slidesDir = dir
+	t.Cleanup(func() { *slidesDir = old })
+	return dir
+}
+
+// writeFiles writes files, keyed by their slash separated names, to dir.
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// slideArchive returns a gzipped tar archive of files.
+func slideArchive(t testing.TB, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// upload posts archive to processUpload, updating existingId if it is set.
+func upload(t testing.TB, archive []byte, existingId string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if existingId != "" {
+		mw.WriteField("existingId", existingId)
+	}
+	part, err := mw.CreateFormFile("slideArchive", "deck.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(archive)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	processUpload(w, r)
+	return w
+}
+
+func TestProcessUploadInvalidatesDeck(t *testing.T) {
+	dir := useSlidesDir(t)
+	slideId, viewId := "cachslid", "cachview"
+	index.addSlide(slideId, viewId)
+	writeFiles(t, filepath.Join(dir, slideId), map[string]string{
+		"main.slide": "Old Title\n\n* Slide\n\nOld text\n",
+	})
+
+	d, err := decks.deck(slideId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.doc.Title != "Old Title" {
+		t.Fatalf("got title %q, want %q", d.doc.Title, "Old Title")
+	}
+
+	archive := slideArchive(t, map[string]string{
+		"main.slide": "New Title\n\n* Slide\n\nNew text\n",
+	})
+	if w := upload(t, archive, slideId); w.Code != http.StatusOK {
+		t.Fatalf("upload: got status %d: %s", w.Code, w.Body)
+	}
+
+	d, err = decks.deck(slideId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.doc.Title != "New Title" {
+		t.Errorf("after update got title %q, want %q", d.doc.Title, "New Title")
+	}
+
+	// A rejected update leaves the deck on disk in place.
+	archive = slideArchive(t, map[string]string{"notes.txt": "no slides"})
+	if w := upload(t, archive, slideId); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad upload: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	decks.invalidate(slideId)
+	d, err = decks.deck(slideId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.doc.Title != "New Title" {
+		t.Errorf("after rejected update got title %q, want %q", d.doc.Title, "New Title")
+	}
+}
+
+func BenchmarkPresentSlide(b *testing.B) {
+	dir := useSlidesDir(b)
+	slideId, viewId := "benchsld", "benchvew"
+	index.addSlide(slideId, viewId)
+	writeFiles(b, filepath.Join(dir, slideId), map[string]string{"main.slide": benchSlide})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			d, err := decks.deck(slideId)
+			if err != nil {
+				b.Fatal(err)
+			}
+			tmpl, err := decks.template(slideId, viewId)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := d.doc.Render(io.Discard, tmpl); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			d, err := loadDeck(slideId)
+			if err != nil {
+				b.Fatal(err)
+			}
+			tmpl, err := parseBaseTemplate()
+			if err != nil {
+				b.Fatal(err)
+			}
+			tmpl.Funcs(slideFuncs(slideId, viewId))
+			if err := d.doc.Render(io.Discard, tmpl); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,200 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode"
+)
+
+const envPrefix = "RPRESENT_"
+
+var configFile = flag.String("config", "", "JSON configuration file")
+
+// configKeys maps configuration file keys to the flags they set. Environment
+// variables use the key in upper snake case, e.g. RPRESENT_SLIDES_DIR.
+var configKeys = []struct{ key, flag string }{
+	{"listen", "http"},
+	{"slidesDir", "d"},
+	{"baseURL", "b"},
+	{"pathPrefix", "prefix"},
+	{"trustedProxies", "trusted-proxies"},
+	{"tlsCert", "tls-cert"},
+	{"tlsKey", "tls-key"},
+	{"httpsRedirect", "https-redirect"},
+	{"shutdownTimeout", "shutdown-timeout"},
+	{"logFormat", "log-format"},
+	{"logLevel", "log-level"},
+	{"htmlMode", "html"},
+	{"play", "play"},
+	{"playTimeout", "play-timeout"},
+	{"playCPU", "play-cpu"},
+	{"playMemory", "play-memory"},
+	{"playUid", "play-uid"},
+	{"playGid", "play-gid"},
+	{"pdfMaxPages", "pdf-max-pages"},
+}
+
+// loadConfig applies the configuration file and environment variables to every
+// flag that was not set on the command line. Flags take precedence over
+// environment variables, which take precedence over the configuration file.
+func loadConfig() error {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	fileValues := make(map[string]string)
+	if *configFile != "" {
+		var err error
+		if fileValues, err = readConfigFile(*configFile); err != nil {
+			return err
+		}
+	}
+
+	for _, ck := range configKeys {
+		if explicit[ck.flag] {
+			continue
+		}
+
+		source := "environment variable " + envName(ck.key)
+		value, ok := os.LookupEnv(envName(ck.key))
+		if !ok {
+			source = *configFile + ": " + ck.key
+			value, ok = fileValues[ck.key]
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := flag.Set(ck.flag, value); err != nil {
+			return fmt.Errorf("%s: invalid value %q: %s", source, value, err)
+		}
+	}
+
+	return validateConfig()
+}
+
+func readConfigFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	// Numbers are kept as written, so that large ones aren't turned into
+	// exponents that the int flags reject.
+	var raw map[string]interface{}
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	known := make(map[string]bool)
+	for _, ck := range configKeys {
+		known[ck.key] = true
+	}
+
+	values := make(map[string]string)
+	for k, v := range raw {
+		if !known[k] {
+			return nil, fmt.Errorf("%s: unknown setting %q", file, k)
+		}
+
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case json.Number:
+			values[k] = v.String()
+		case bool:
+			values[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", file, k)
+		}
+	}
+
+	return values, nil
+}
+
+func validateConfig() error {
+	if *httpAddr == "" {
+		return errors.New("listen address must not be empty")
+	}
+
+	if *slidesDir == "" {
+		return errors.New("slides directory must not be empty")
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return errors.New("both TLS certificate and key must be set to serve HTTPS")
+	}
+
+	if *redirectAddr != "" && *tlsCert == "" {
+		return errors.New("HTTPS redirect requires a TLS certificate and key")
+	}
+
+	if *shutdownTimeout <= 0 {
+		return errors.New("shutdown timeout must be positive")
+	}
+
+	if *playEnabled && (*playTimeout <= 0 || *playCPU < time.Second || *playMemory <= 0) {
+		return errors.New("play limits must be positive, with at least a second of CPU time")
+	}
+
+	if *playEnabled && (*playUid <= 0 || *playGid <= 0) {
+		return errors.New("snippets must run as an unprivileged user and group")
+	}
+
+	if *playEnabled && os.Geteuid() != 0 {
+		return errors.New("running snippets requires root, to switch to their user")
+	}
+
+	switch *htmlMode {
+	case htmlAllow, htmlSanitize, htmlReject:
+	default:
+		return fmt.Errorf("invalid HTML mode: %s", *htmlMode)
+	}
+
+	return nil
+}
+
+// printConfig writes the effective configuration in the configuration file
+// format, so that its output can be used as a configuration file.
+func printConfig(w io.Writer) error {
+	values := make(map[string]string)
+	for _, ck := range configKeys {
+		values[ck.key] = flag.Lookup(ck.flag).Value.String()
+	}
+
+	out, err := json.MarshalIndent(values, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// envName converts a camel case configuration key to its environment variable.
+func envName(key string) string {
+	var name []rune
+	for i, c := range key {
+		if unicode.IsUpper(c) && i > 0 && !unicode.IsUpper(rune(key[i-1])) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToUpper(c))
+	}
+
+	return envPrefix + string(name)
+}
@@ -0,0 +1,301 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/present"
+)
+
+// deckMetaFile is an optional file in a slide archive with deck level settings.
+const deckMetaFile = "deck.json"
+
+// deckEntryFile records the slide file of a deck, which is written when the
+// deck is uploaded. Being a dotfile, it is never served.
+const deckEntryFile = ".entry"
+
+// deckThemeFile is an optional stylesheet in a slide archive, layered over
+// styles.css and the deck's built-in theme.
+const deckThemeFile = "theme.css"
+
+const (
+	layoutWidescreen     = "widescreen"
+	layoutFauxWidescreen = "faux-widescreen"
+	layoutStandard       = "4:3"
+)
+
+type deck struct {
+	doc      *present.Doc
+	meta     deckMeta
+	themeCSS bool
+}
+
+type deckMeta struct {
+	// FrameOrigins lists origins that .iframe elements may load pages from.
+	FrameOrigins []string `json:"frameOrigins"`
+
+	// Fonts lists font files in the archive that the deck can use.
+	Fonts []deckFont `json:"fonts"`
+
+	// Font and CodeFont name the font families used for text and code,
+	// instead of the built-in Open Sans and Source Code Pro.
+	Font     string `json:"font"`
+	CodeFont string `json:"codeFont"`
+
+	// Theme names one of the built-in themes.
+	Theme string `json:"theme"`
+
+	// Layout is widescreen, faux-widescreen or 4:3. Decks are widescreen
+	// by default.
+	Layout string `json:"layout"`
+
+	// Main names the slide file to present in archives with several decks.
+	Main string `json:"main"`
+}
+
+type deckFont struct {
+	Family string `json:"family"`
+	Src    string `json:"src"`
+	Weight string `json:"weight"`
+	Style  string `json:"style"`
+}
+
+// fontFamilyPattern limits font family names to those that can't break out
+// of the stylesheet they are used in.
+var fontFamilyPattern = regexp.MustCompile(`^[A-Za-z0-9 _-]+$`)
+
+// fontSrcPattern limits font file names to those that are safe to use in a
+// stylesheet without quoting.
+var fontSrcPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+var fontWeightPattern = regexp.MustCompile(`^(normal|bold|[1-9]00)?$`)
+
+var fontStylePattern = regexp.MustCompile(`^(normal|italic)?$`)
+
+func loadDeck(slideId string) (*deck, error) {
+	deckDir := filepath.Join(*slidesDir, slideId)
+
+	meta, err := loadDeckMeta(filepath.Join(deckDir, deckMetaFile))
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseDeck(deckDir)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filepath.Join(deckDir, deckThemeFile))
+	themeCSS := err == nil && !info.IsDir()
+
+	return &deck{doc: doc, meta: meta, themeCSS: themeCSS}, nil
+}
+
+// deckSource returns the slide file of the deck in deckDir, relative to it.
+// Decks uploaded before entry points were recorded use main.slide or main.md.
+func deckSource(deckDir string) string {
+	if entry, err := os.ReadFile(filepath.Join(deckDir, deckEntryFile)); err == nil {
+		return strings.TrimSpace(string(entry))
+	}
+
+	if _, err := os.Stat(filepath.Join(deckDir, markdownFile)); err == nil {
+		if _, err := os.Stat(filepath.Join(deckDir, "main.slide")); os.IsNotExist(err) {
+			return markdownFile
+		}
+	}
+	return "main.slide"
+}
+
+// checkEntry checks that entry names a slide file, Markdown file or PDF
+// document in deckDir.
+func checkEntry(deckDir, entry string) error {
+	switch strings.ToLower(path.Ext(entry)) {
+	case ".slide", ".md", ".pdf":
+	default:
+		return fmt.Errorf("entry point must be a .slide, .md or .pdf file: %s", entry)
+	}
+
+	file, ok := confinedPath(deckDir, entry)
+	if !ok {
+		return fmt.Errorf("entry point must be inside the archive: %s", entry)
+	}
+
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return fmt.Errorf("entry point not found in archive: %s", entry)
+	}
+
+	return nil
+}
+
+// parseDeck parses the slide file of the deck in deckDir. Files included by
+// .code, .play and .html commands are read relative to the slide file and
+// must not be outside deckDir. Image URLs are made relative to deckDir.
+func parseDeck(deckDir string) (*present.Doc, error) {
+	source := deckSource(deckDir)
+	file, ok := confinedPath(deckDir, source)
+	if !ok {
+		return nil, fmt.Errorf("%s: file must be inside the archive", source)
+	}
+
+	var doc *present.Doc
+	var err error
+	if isMarkdown(source) {
+		doc, err = parseMarkdownDeck(file)
+	} else {
+		doc, err = parsePresentDeck(deckDir, file, source)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := path.Dir(source); dir != "." {
+		for i := range doc.Sections {
+			resolveRefs(doc.Sections[i].Elem, dir)
+		}
+	}
+
+	return doc, nil
+}
+
+func parsePresentDeck(deckDir, file, source string) (*present.Doc, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	ctx := &present.Context{ReadFile: func(name string) ([]byte, error) {
+		file, ok := confinedPath(deckDir, filepath.ToSlash(name))
+		if !ok {
+			return nil, fmt.Errorf("%s: file must be inside the archive", name)
+		}
+		return os.ReadFile(file)
+	}}
+
+	return ctx.Parse(f, source, 0)
+}
+
+// resolveRefs makes the deck relative image, iframe and link URLs in elems,
+// which are relative to dir, relative to the deck directory.
+func resolveRefs(elems []present.Elem, dir string) {
+	for i, e := range elems {
+		switch e := e.(type) {
+		case present.Section:
+			resolveRefs(e.Elem, dir)
+		case present.Image:
+			e.URL = resolveRef(e.URL, dir)
+			elems[i] = e
+		case present.Iframe:
+			e.URL = resolveRef(e.URL, dir)
+			elems[i] = e
+		case present.Link:
+			if e.URL != nil {
+				if u, err := url.Parse(resolveRef(e.URL.String(), dir)); err == nil {
+					e.URL = u
+					elems[i] = e
+				}
+			}
+		}
+	}
+}
+
+// resolveRef joins ref to dir if it refers to a file in the deck.
+func resolveRef(ref, dir string) string {
+	u, err := url.Parse(ref)
+	if err != nil || !deckRelative(u) {
+		return ref
+	}
+
+	u.Path = path.Join(dir, u.Path)
+	return u.String()
+}
+
+// deckRelative reports whether u refers to a file in the deck, rather than
+// to another site, a path on the server or a fragment of the page.
+func deckRelative(u *url.URL) bool {
+	return !u.IsAbs() && u.Host == "" && u.Path != "" && !strings.HasPrefix(u.Path, "/")
+}
+
+func loadDeckMeta(file string) (meta deckMeta, err error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&meta); err != nil {
+		return meta, fmt.Errorf("%s: %s", deckMetaFile, err)
+	}
+
+	return meta, meta.validate()
+}
+
+func (meta deckMeta) validate() error {
+	for _, origin := range meta.FrameOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("%s: invalid frame origin %q", deckMetaFile, origin)
+		}
+	}
+
+	if _, ok := builtinThemes[meta.Theme]; meta.Theme != "" && !ok {
+		return fmt.Errorf("%s: unknown theme %q", deckMetaFile, meta.Theme)
+	}
+
+	switch meta.Layout {
+	case "", layoutWidescreen, layoutFauxWidescreen, layoutStandard:
+	default:
+		return fmt.Errorf("%s: layout must be %s, %s or %s", deckMetaFile, layoutWidescreen, layoutFauxWidescreen, layoutStandard)
+	}
+
+	for _, family := range []string{meta.Font, meta.CodeFont} {
+		if family != "" && !fontFamilyPattern.MatchString(family) {
+			return fmt.Errorf("%s: invalid font family %q", deckMetaFile, family)
+		}
+	}
+
+	for _, font := range meta.Fonts {
+		switch {
+		case !fontFamilyPattern.MatchString(font.Family):
+			return fmt.Errorf("%s: invalid font family %q", deckMetaFile, font.Family)
+		case !fontSrcPattern.MatchString(font.Src):
+			return fmt.Errorf("%s: invalid font file name %q", deckMetaFile, font.Src)
+		case fontTypes[strings.ToLower(path.Ext(font.Src))] == "":
+			return fmt.Errorf("%s: font file must be WOFF2, WOFF, TTF or OTF: %s", deckMetaFile, font.Src)
+		case !fontWeightPattern.MatchString(font.Weight):
+			return fmt.Errorf("%s: invalid font weight %q", deckMetaFile, font.Weight)
+		case !fontStylePattern.MatchString(font.Style):
+			return fmt.Errorf("%s: invalid font style %q", deckMetaFile, font.Style)
+		}
+	}
+
+	return nil
+}
+
+// layoutClass returns the class of the slides element for the deck's layout.
+func (meta deckMeta) layoutClass() string {
+	switch meta.Layout {
+	case layoutFauxWidescreen:
+		return "layout-faux-widescreen"
+	case layoutStandard:
+		return ""
+	}
+	return "layout-widescreen"
+}
@@ -0,0 +1,79 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"mime"
+	"path"
+	"strings"
+)
+
+// fontFiles holds the fonts used by styles.css, so that decks don't depend on
+// a third party font service. They are served from /static/fonts/.
+//
+//go:embed fonts/*.woff2
+var fontFiles embed.FS
+
+// fontTypes lists the font formats decks may include.
+var fontTypes = map[string]string{
+	".woff2": "font/woff2",
+	".woff":  "font/woff",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+}
+
+func init() {
+	for ext, contentType := range fontTypes {
+		mime.AddExtensionType(ext, contentType)
+	}
+
+	files, err := fontFiles.ReadDir("fonts")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, f := range files {
+		content, err := fontFiles.ReadFile(path.Join("fonts", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		// Fonts are already compressed, so they aren't gzipped again.
+		staticAssets["fonts/"+f.Name()] = &staticAsset{
+			contentType: fontTypes[path.Ext(f.Name())],
+			etag:        `"` + contentHash(string(content)) + `"`,
+			content:     content,
+		}
+	}
+}
+
+// fontCSS returns the stylesheet declaring the fonts included in a deck and
+// applying its font settings. Font files are loaded from resPrefix. The names
+// used are validated along with meta, so they are safe to use as is.
+func (meta deckMeta) fontCSS(resPrefix, version string) template.CSS {
+	var css strings.Builder
+	for _, font := range meta.Fonts {
+		fmt.Fprintf(&css, "@font-face { font-family: '%s'; src: url('%s%s?v=%s');", font.Family, resPrefix, font.Src, version)
+		if font.Weight != "" {
+			fmt.Fprintf(&css, " font-weight: %s;", font.Weight)
+		}
+		if font.Style != "" {
+			fmt.Fprintf(&css, " font-style: %s;", font.Style)
+		}
+		css.WriteString(" }\n")
+	}
+
+	if meta.Font != "" {
+		fmt.Fprintf(&css, "body .slides > article { font-family: '%s', 'Open Sans', Arial, sans-serif; }\n", meta.Font)
+	}
+	if meta.CodeFont != "" {
+		fmt.Fprintf(&css, "body .slides > article pre, body .slides > article code { font-family: '%s', 'Source Code Pro', 'Courier New', monospace; }\n", meta.CodeFont)
+	}
+
+	return template.CSS(css.String())
+}
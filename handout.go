@@ -0,0 +1,72 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"html/template"
+	"io"
+
+	"golang.org/x/tools/present"
+)
+
+// renderHandout writes all slides of d as a printable page, along with the
+// speaker notes when withNotes is set.
+func renderHandout(w io.Writer, d *deck, tmpl *template.Template, withNotes bool) error {
+	data := struct {
+		*present.Doc
+		Template  *template.Template
+		WithNotes bool
+	}{d.doc, tmpl, withNotes}
+
+	return tmpl.ExecuteTemplate(w, "handout", data)
+}
+
+const handoutTmpl = `
+{{define "handout"}}
+<!DOCTYPE html>
+<html>
+  <head>
+    <title>{{.Title}}</title>
+    <meta charset='utf-8'>
+    <link rel='stylesheet' href='{{pathPrefix}}/static/styles.css?v={{staticVersion}}'>
+    {{range themeStyles}}<link rel='stylesheet' href='{{.}}'>
+    {{end}}
+    <link rel='stylesheet' href='{{pathPrefix}}/static/print.css?v={{staticVersion}}'>
+    {{with deckStyle}}<style>{{.}}</style>{{end}}
+  </head>
+
+  <body>
+
+    <section class='slides {{layoutClass}} handout'>
+
+      <article>
+        <h1>{{.Title}}</h1>
+        {{with .Subtitle}}<h3>{{.}}</h3>{{end}}
+        {{if not .Time.IsZero}}<h3>{{.Time.Format "2 January 2006"}}</h3>{{end}}
+        {{range .Authors}}
+          <div class="presenter">
+            {{range .TextElem}}{{elem $.Template .}}{{end}}
+          </div>
+        {{end}}
+        {{if .WithNotes}}{{template "notes" .TitleNotes}}{{end}}
+      </article>
+
+  {{range $i, $s := .Sections}}
+      <article>
+      {{if $s.Elem}}
+        <h3>{{$s.Title}}</h3>
+        {{range $s.Elem}}{{elem $.Template .}}{{end}}
+      {{else}}
+        <h2>{{$s.Title}}</h2>
+      {{end}}
+      {{if $.WithNotes}}{{template "notes" $s.Notes}}{{end}}
+      </article>
+  {{end}}
+
+    </section>
+
+  </body>
+</html>
+{{end}}`
@@ -0,0 +1,91 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+const registryProbeTimeout = time.Second
+
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// readyz reports whether the server can accept uploads and connections.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"slidesDir": checkResult(checkSlidesDir()),
+		"index":     checkResult(checkIndex()),
+		"registry":  checkResult(checkRegistry()),
+		"shutdown":  checkResult(checkShutdown()),
+	}
+
+	status, code := "ok", http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+
+	writeStatus(w, code, map[string]interface{}{"status": status, "checks": checks})
+}
+
+func writeStatus(w http.ResponseWriter, code int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func checkResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func checkSlidesDir() error {
+	f, err := os.CreateTemp(*slidesDir, ".readyz")
+	if err != nil {
+		return errors.New("slides directory is not writable")
+	}
+
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func checkIndex() error {
+	if !index.isLoaded() {
+		return errors.New("index not loaded")
+	}
+	return nil
+}
+
+func checkRegistry() error {
+	done := make(chan struct{})
+	go func() {
+		registry.counts()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(registryProbeTimeout):
+		return errors.New("listener registry not responding")
+	}
+}
+
+func checkShutdown() error {
+	if isShuttingDown() {
+		return errors.New("shutting down")
+	}
+	return nil
+}
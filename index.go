@@ -16,11 +16,13 @@ type slideIndex struct {
 	sync.RWMutex
 	slides map[string]string
 	views  map[string]string
+	loaded bool
 }
 
 func (idx *slideIndex) load(file string) error {
 	f, err := os.Open(file)
 	if os.IsNotExist(err) {
+		idx.setLoaded()
 		return nil
 	}
 	if err != nil {
@@ -36,9 +38,23 @@ func (idx *slideIndex) load(file string) error {
 	for k, v := range idx.slides {
 		idx.views[v] = k
 	}
+
+	idx.setLoaded()
 	return nil
 }
 
+func (idx *slideIndex) setLoaded() {
+	idx.Lock()
+	defer idx.Unlock()
+	idx.loaded = true
+}
+
+func (idx *slideIndex) isLoaded() bool {
+	idx.RLock()
+	defer idx.RUnlock()
+	return idx.loaded
+}
+
 func (idx *slideIndex) addSlide(slideId, viewId string) {
 	idx.Lock()
 	defer idx.Unlock()
@@ -0,0 +1,125 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const redacted = "[redacted]"
+
+var (
+	logFormat = flag.String("log-format", "logfmt", "Log format: logfmt or json")
+	logLevel  = flag.String("log-level", "info", "Log level: debug, info, warn or error")
+)
+
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("invalid log level: %s", *logLevel)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch *logFormat {
+	case "logfmt":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format: %s", *logFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// redactPath replaces presenter IDs in an URL path, so that presenter secrets
+// never reach the logs. View IDs are left alone as they are shared publicly.
+func redactPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if _, viewId := index.getIdPair(part); viewId != "" {
+			parts[i] = redacted
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// redactId hides slideId wherever it appears in msg, such as in file paths of
+// errors returned while handling a deck.
+func redactId(msg, slideId string) string {
+	if slideId == "" {
+		return msg
+	}
+	return strings.Replace(msg, slideId, redacted, -1)
+}
+
+// logRequests logs every request handled by h once it completes.
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h.ServeHTTP(rec, r)
+
+		slog.Info("request",
+			"method", r.Method,
+			"path", redactPath(r.URL.Path),
+			"status", rec.status,
+			"bytes", rec.size,
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket handlers take over the connection.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+
+	rec.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
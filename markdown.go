@@ -0,0 +1,367 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/tools/present"
+)
+
+// markdownFile is the slide file of Markdown decks when the archive doesn't
+// name one.
+const markdownFile = "main.md"
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// rawMarkdown renders raw HTML, which markdown leaves out.
+var rawMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(gmhtml.WithUnsafe()))
+
+// isMarkdown reports whether the slide file source is written in Markdown.
+func isMarkdown(source string) bool {
+	return strings.EqualFold(path.Ext(source), ".md")
+}
+
+// markdownSlide is the source of one slide in a Markdown deck.
+type markdownSlide struct {
+	source []byte
+	notes  []string
+}
+
+// parseMarkdownDeck parses the Markdown slide file into the same structure
+// present decks parse to, so that both are rendered the same way.
+//
+// Slides are separated by lines of ---, and the first heading of a slide is
+// its title. The first slide is the title slide, where a second heading is
+// the subtitle and every paragraph names an author. Lines starting with ": "
+// are speaker notes, as in present decks.
+func parseMarkdownDeck(file string) (*present.Doc, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := new(present.Doc)
+	for i, slide := range splitMarkdownSlides(content) {
+		c := &markdownConverter{source: slide.source, titleSlide: i == 0}
+		elems := c.convert(markdown.Parser().Parse(text.NewReader(slide.source)))
+
+		if i > 0 {
+			doc.Sections = append(doc.Sections, present.Section{
+				Number: []int{i},
+				Title:  c.title,
+				Elem:   elems,
+				Notes:  slide.notes,
+			})
+			continue
+		}
+
+		doc.Title = c.title
+		doc.Subtitle = c.subtitle
+		doc.TitleNotes = slide.notes
+		for _, e := range elems {
+			if t, ok := e.(present.Text); ok {
+				doc.Authors = append(doc.Authors, present.Author{Elem: []present.Elem{t}})
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// splitMarkdownSlides splits source at lines of --- outside of fenced code
+// blocks, taking out speaker notes.
+func splitMarkdownSlides(source []byte) []markdownSlide {
+	var slides []markdownSlide
+	var slide markdownSlide
+	var buf bytes.Buffer
+	fence := ""
+
+	for _, line := range strings.SplitAfter(string(source), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+
+		case trimmed == "---":
+			if strings.TrimSpace(buf.String()) != "" || len(slide.notes) > 0 {
+				slide.source = append([]byte(nil), buf.Bytes()...)
+				slides = append(slides, slide)
+			}
+			slide = markdownSlide{}
+			buf.Reset()
+			continue
+
+		case strings.HasPrefix(line, ": ") || trimmed == ":":
+			slide.notes = append(slide.notes, strings.TrimSpace(strings.TrimPrefix(line, ":")))
+			continue
+		}
+
+		buf.WriteString(line)
+	}
+
+	slide.source = buf.Bytes()
+	return append(slides, slide)
+}
+
+// markdownConverter converts the blocks of a Markdown slide to present
+// elements, turning inline formatting into present's markup.
+type markdownConverter struct {
+	source     []byte
+	titleSlide bool
+	title      string
+	subtitle   string
+}
+
+func (c *markdownConverter) convert(doc ast.Node) []present.Elem {
+	var elems []present.Elem
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if e := c.block(n); e != nil {
+			elems = append(elems, e)
+		}
+	}
+	return elems
+}
+
+func (c *markdownConverter) block(n ast.Node) present.Elem {
+	switch n := n.(type) {
+	case *ast.Heading:
+		title := c.plainText(n)
+		switch {
+		case c.title == "":
+			c.title = title
+		case c.titleSlide && c.subtitle == "":
+			c.subtitle = title
+		case inlineHTML(n):
+			return c.fallback(n)
+		default:
+			return present.Text{Lines: []string{emphasize("*", title)}}
+		}
+
+	case *ast.Paragraph:
+		if inlineHTML(n) {
+			return c.fallback(n)
+		}
+		if img, ok := n.FirstChild().(*ast.Image); ok && n.ChildCount() == 1 {
+			if u, err := url.Parse(string(img.Destination)); err == nil && !u.IsAbs() {
+				return present.Image{URL: u.Path}
+			}
+		}
+		return present.Text{Lines: strings.Split(c.markup(n), "\n")}
+
+	case *ast.List:
+		if list, ok := c.list(n); ok && !inlineHTML(n) {
+			return list
+		}
+		return c.fallback(n)
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		code := strings.TrimRight(string(c.lines(n)), "\n")
+		return present.Code{Text: template.HTML("<pre>" + html.EscapeString(code) + "</pre>")}
+
+	case *ast.HTMLBlock:
+		raw := c.lines(n)
+		if n.HasClosure() {
+			raw = append(raw, n.ClosureLine.Value(c.source)...)
+		}
+		return rawHTML(raw)
+
+	case *ast.ThematicBreak:
+
+	default:
+		return c.fallback(n)
+	}
+
+	return nil
+}
+
+// list converts lists whose items are single paragraphs.
+func (c *markdownConverter) list(n *ast.List) (present.List, bool) {
+	var list present.List
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		if item.ChildCount() != 1 {
+			return list, false
+		}
+
+		switch item.FirstChild().(type) {
+		case *ast.TextBlock, *ast.Paragraph:
+			list.Bullet = append(list.Bullet, strings.Replace(c.markup(item.FirstChild()), "\n", " ", -1))
+		default:
+			return list, false
+		}
+	}
+	return list, true
+}
+
+// fallback renders blocks present has no element for, such as tables and
+// quotes, and blocks containing inline HTML as HTML. Raw HTML is treated as
+// htmlMode says and unsafe links are left out.
+func (c *markdownConverter) fallback(n ast.Node) present.Elem {
+	renderer, raw := markdown.Renderer(), inlineHTML(n)
+	if raw {
+		renderer = rawMarkdown.Renderer()
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, c.source, n); err != nil {
+		return nil
+	}
+
+	if raw {
+		return rawHTML(buf.Bytes())
+	}
+	return present.HTML{HTML: template.HTML(buf.String())}
+}
+
+// rawHTML returns raw as htmlMode allows, which is nothing when HTML is
+// rejected.
+func rawHTML(raw []byte) present.Elem {
+	switch *htmlMode {
+	case htmlAllow:
+		return present.HTML{HTML: template.HTML(raw)}
+	case htmlSanitize:
+		var buf bytes.Buffer
+		if sanitizeHTML(&buf, bytes.NewReader(raw)) == nil {
+			return present.HTML{HTML: template.HTML(buf.String())}
+		}
+	}
+	return nil
+}
+
+// inlineHTML reports whether n contains raw HTML that htmlMode doesn't reject,
+// so that it must be rendered by fallback.
+func inlineHTML(n ast.Node) bool {
+	if *htmlMode == htmlReject {
+		return false
+	}
+
+	found := false
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if _, ok := n.(*ast.RawHTML); ok {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+func (c *markdownConverter) lines(n ast.Node) []byte {
+	var buf []byte
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		buf = append(buf, seg.Value(c.source)...)
+	}
+	return buf
+}
+
+// markup returns the inline content of n in present's markup.
+func (c *markdownConverter) markup(n ast.Node) string {
+	var buf strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch child := child.(type) {
+		case *ast.Text:
+			buf.Write(child.Value(c.source))
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				buf.WriteByte('\n')
+			}
+
+		case *ast.String:
+			buf.Write(child.Value)
+
+		case *ast.Emphasis:
+			marker := "_"
+			if child.Level > 1 {
+				marker = "*"
+			}
+			buf.WriteString(emphasize(marker, c.plainText(child)))
+
+		case *ast.CodeSpan:
+			buf.WriteString(emphasize("`", c.plainText(child)))
+
+		case *ast.Link:
+			c.link(&buf, string(child.Destination), c.plainText(child))
+
+		case *ast.AutoLink:
+			c.link(&buf, string(child.URL(c.source)), string(child.Label(c.source)))
+
+		case *ast.Image:
+			buf.WriteString(c.plainText(child))
+
+		case *ast.RawHTML:
+			// Left out, as blocks with raw HTML are rendered by fallback
+			// unless htmlMode rejects it.
+
+		default:
+			buf.WriteString(c.markup(child))
+		}
+	}
+	return buf.String()
+}
+
+func (c *markdownConverter) link(buf *strings.Builder, dest, label string) {
+	if !safeURL(dest) || strings.ContainsAny(dest, "[] ") {
+		buf.WriteString(label)
+		return
+	}
+	fmt.Fprintf(buf, "[[%s][%s]]", dest, strings.Replace(label, "]", "", -1))
+}
+
+// plainText returns the text of the inline content of n.
+func (c *markdownConverter) plainText(n ast.Node) string {
+	var buf strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch child := child.(type) {
+		case *ast.Text:
+			buf.Write(child.Value(c.source))
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(child.Value)
+		default:
+			buf.WriteString(c.plainText(child))
+		}
+	}
+	return buf.String()
+}
+
+// emphasize marks up s with marker, which present applies to single words.
+// Spaces in s are written as the marker and markers in s are doubled.
+func emphasize(marker, s string) string {
+	s = strings.Replace(s, marker, marker+marker, -1)
+	return marker + strings.Replace(s, " ", marker, -1) + marker
+}
+
+// markdownLine returns the line of source that n, or the block containing
+// it, starts at.
+func markdownLine(source []byte, n ast.Node) int {
+	for ; n != nil; n = n.Parent() {
+		if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+			return bytes.Count(source[:n.Lines().At(0).Start], []byte("\n")) + 1
+		}
+	}
+	return 1
+}
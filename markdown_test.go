@@ -0,0 +1,106 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/tools/present"
+)
+
+func TestSplitMarkdownSlides(t *testing.T) {
+	tests := []struct {
+		in     string
+		slides []string
+		notes  [][]string
+	}{
+		{"# Title\n", []string{"# Title\n"}, [][]string{nil}},
+		{"# A\n---\n# B\n", []string{"# A\n", "# B\n"}, [][]string{nil, nil}},
+		{"---\n# A\n---\n\n---\n# B", []string{"# A\n", "# B"}, [][]string{nil, nil}},
+		{"# A\n  ---  \n# B\n", []string{"# A\n", "# B\n"}, [][]string{nil, nil}},
+		{"# A\n----\n", []string{"# A\n----\n"}, [][]string{nil}},
+		{"# A\n---\n", []string{"# A\n", ""}, [][]string{nil, nil}},
+
+		{"```\n---\n```\n---\nB\n", []string{"```\n---\n```\n", "B\n"}, [][]string{nil, nil}},
+		{"~~~go\n---\n: x\n~~~\n", []string{"~~~go\n---\n: x\n~~~\n"}, [][]string{nil}},
+
+		{"# A\n: first note\n:\n: second\n", []string{"# A\n"}, [][]string{{"first note", "", "second"}}},
+		{": only notes\n---\nB\n", []string{"", "B\n"}, [][]string{{"only notes"}, nil}},
+		{"a: b\n:not a note\n", []string{"a: b\n:not a note\n"}, [][]string{nil}},
+	}
+
+	for _, test := range tests {
+		slides := splitMarkdownSlides([]byte(test.in))
+		if len(slides) != len(test.slides) {
+			t.Errorf("splitMarkdownSlides(%q) returned %d slides, want %d", test.in, len(slides), len(test.slides))
+			continue
+		}
+
+		for i, slide := range slides {
+			if string(slide.source) != test.slides[i] {
+				t.Errorf("splitMarkdownSlides(%q)[%d].source = %q, want %q", test.in, i, slide.source, test.slides[i])
+			}
+			if !reflect.DeepEqual(slide.notes, test.notes[i]) {
+				t.Errorf("splitMarkdownSlides(%q)[%d].notes = %q, want %q", test.in, i, slide.notes, test.notes[i])
+			}
+		}
+	}
+}
+
+func TestMarkdownConverter(t *testing.T) {
+	tests := []struct {
+		in    string
+		mode  string
+		title string
+		elems []present.Elem
+	}{
+		{"# Title\n\nText", htmlAllow, "Title", []present.Elem{present.Text{Lines: []string{"Text"}}}},
+		{"# Title\n## Sub", htmlAllow, "Title", []present.Elem{present.Text{Lines: []string{"*Sub*"}}}},
+		{"**a b** _c_ `d`", htmlAllow, "", []present.Elem{present.Text{Lines: []string{"*a*b* _c_ `d`"}}}},
+		{"[x](https://example.com/) [y](javascript:alert(1))", htmlAllow, "",
+			[]present.Elem{present.Text{Lines: []string{"[[https://example.com/][x]] y"}}}},
+		{"- a\n- *b*", htmlAllow, "", []present.Elem{present.List{Bullet: []string{"a", "_b_"}}}},
+
+		{"```go\nif a < b {\n}\n```", htmlAllow, "",
+			[]present.Elem{present.Code{Text: "<pre>if a &lt; b {\n}</pre>"}}},
+		{"    indented\n    code", htmlAllow, "",
+			[]present.Elem{present.Code{Text: "<pre>indented\ncode</pre>"}}},
+
+		{"![Diagram](img/diagram.png)", htmlAllow, "", []present.Elem{present.Image{URL: "img/diagram.png"}}},
+		{"![Remote](https://example.com/a.png)", htmlAllow, "", []present.Elem{present.Text{Lines: []string{"Remote"}}}},
+		{"See ![x](a.png)", htmlAllow, "", []present.Elem{present.Text{Lines: []string{"See x"}}}},
+
+		{"<div>block</div>", htmlAllow, "", []present.Elem{present.HTML{HTML: "<div>block</div>"}}},
+		{"<div onclick=\"x()\">block</div>", htmlSanitize, "", []present.Elem{present.HTML{HTML: "<div>block</div>"}}},
+		{"<div>block</div>", htmlReject, "", nil},
+
+		{"a <b onclick=\"x()\">b</b> c", htmlAllow, "",
+			[]present.Elem{present.HTML{HTML: "<p>a <b onclick=\"x()\">b</b> c</p>\n"}}},
+		{"a <b onclick=\"x()\">b</b> c", htmlSanitize, "",
+			[]present.Elem{present.HTML{HTML: "<p>a <b>b</b> c</p>\n"}}},
+		{"a <b onclick=\"x()\">b</b> c", htmlReject, "", []present.Elem{present.Text{Lines: []string{"a b c"}}}},
+		{"- a <script>x()</script>", htmlSanitize, "",
+			[]present.Elem{present.HTML{HTML: "<ul>\n<li>a </li>\n</ul>\n"}}},
+	}
+
+	defer func(mode string) { *htmlMode = mode }(*htmlMode)
+
+	for _, test := range tests {
+		*htmlMode = test.mode
+
+		source := []byte(test.in)
+		c := &markdownConverter{source: source}
+		elems := c.convert(markdown.Parser().Parse(text.NewReader(source)))
+
+		if c.title != test.title {
+			t.Errorf("%s: converting %q gave title %q, want %q", test.mode, test.in, c.title, test.title)
+		}
+		if !reflect.DeepEqual(elems, test.elems) {
+			t.Errorf("%s: converting %q gave %#v, want %#v", test.mode, test.in, elems, test.elems)
+		}
+	}
+}
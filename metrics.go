@@ -0,0 +1,160 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var latencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+var (
+	uploadsTotal = newCounterVec("rpresent_uploads_total",
+		"Slide archive uploads by result.", "result")
+	disconnectsTotal = newCounterVec("rpresent_websocket_disconnects_total",
+		"WebSocket disconnects by role and reason.", "role", "reason")
+	fanoutSeconds = newHistogram("rpresent_slide_fanout_seconds",
+		"Time from a presenter changing slide until a viewer is sent the change.", latencyBuckets)
+	renderSeconds = newHistogram("rpresent_render_seconds",
+		"Time taken to render a deck in presentSlide.", latencyBuckets)
+)
+
+// metrics serves all metrics in the Prometheus text exposition format.
+func metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	uploadsTotal.write(w)
+	disconnectsTotal.write(w)
+	fanoutSeconds.write(w)
+	renderSeconds.write(w)
+
+	presenters, viewers := registry.counts()
+	writeDeckGauge(w, "rpresent_presenters", "Connected presenters per deck.", presenters)
+	writeDeckGauge(w, "rpresent_viewers", "Connected viewers per deck.", viewers)
+}
+
+// writeDeckGauge writes counts keyed by slide ID. Decks are labelled with a
+// hash of the slide ID, as /metrics is public and a view ID is enough to join
+// a talk.
+func writeDeckGauge(w io.Writer, name, help string, counts map[string]int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+
+	var lines []string
+	for slideId, count := range counts {
+		lines = append(lines, fmt.Sprintf("%s{deck=%s} %d\n", name, labelValue(contentHash(slideId)), count))
+	}
+
+	sort.Strings(lines)
+	io.WriteString(w, strings.Join(lines, ""))
+}
+
+// disconnectReason classifies the error that ended a WebSocket connection.
+func disconnectReason(err error) string {
+	if isShuttingDown() {
+		return "shutdown"
+	}
+
+	if err == io.EOF {
+		return "closed"
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "timeout"
+	}
+
+	return "error"
+}
+
+type counterVec struct {
+	sync.Mutex
+	name, help string
+	labels     []string
+	values     map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	var pairs []string
+	for i, label := range c.labels {
+		pairs = append(pairs, label+"="+labelValue(labelValues[i]))
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.values["{"+strings.Join(pairs, ",")+"}"]++
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %g\n", c.name, k, c.values[k])
+	}
+}
+
+type histogram struct {
+	sync.Mutex
+	name, help string
+	buckets    []float64
+	counts     []uint64
+	sum        float64
+	count      uint64
+}
+
+func newHistogram(name, help string, buckets []float64) *histogram {
+	return &histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+
+	h.Lock()
+	defer h.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", h.name, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", h.name, h.sum, h.name, h.count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
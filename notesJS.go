@@ -0,0 +1,98 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+const notesJS = `
+var NOTES_SLIDE_KEY = "rpresent-slide-" + rSlideId;
+var presenterView = window.location.search == "?presenter";
+var presenterWindow = null;
+var timerStart = new Date().getTime();
+
+document.addEventListener("DOMContentLoaded", function() {
+  if(userRole != "p") {
+    return;
+  }
+
+  document.addEventListener("slideenter", function(event) {
+    localStorage.setItem(NOTES_SLIDE_KEY, event.slideNumber);
+    if(presenterView) {
+      showNotes(event.target);
+    }
+  }, false);
+
+  // Keep the presenter tab and the presenter window on the same slide.
+  window.addEventListener("storage", function(event) {
+    if(event.key != NOTES_SLIDE_KEY || !event.newValue) {
+      return;
+    }
+
+    var slideNo = Number(event.newValue) - 1;
+    if(slideNo != curSlide) {
+      curSlide = slideNo;
+      updateSlides();
+    }
+  }, false);
+
+  if(presenterView) {
+    setupPresenterView();
+    return;
+  }
+
+  document.addEventListener("keypress", function(event) {
+    if(event.target.classList.contains("code")) {
+      return;
+    }
+
+    if(event.charCode == 78 || event.charCode == 110) {
+      openPresenterWindow();
+    }
+  }, false);
+});
+
+function openPresenterWindow() {
+  if(presenterWindow && !presenterWindow.closed) {
+    presenterWindow.focus();
+    return;
+  }
+
+  presenterWindow = window.open(window.location.pathname + "?presenter#" + (curSlide + 1),
+    "rpresent-presenter-" + rSlideId, "width=1140,height=800");
+}
+
+function setupPresenterView() {
+  document.body.classList.add("presenter-view");
+  document.title = "Presenter - " + document.title;
+
+  var timer = document.createElement("div");
+  timer.id = "presenter-timer";
+  timer.title = "Click to reset";
+  timer.addEventListener("click", function() {
+    timerStart = new Date().getTime();
+    updateTimer();
+  }, false);
+  document.body.appendChild(timer);
+
+  var notes = document.createElement("div");
+  notes.id = "presenter-notes";
+  document.body.appendChild(notes);
+
+  updateTimer();
+  window.setInterval(updateTimer, 1000);
+  showNotes(getSlideEl(curSlide));
+}
+
+function showNotes(slide) {
+  var notes = document.getElementById("presenter-notes");
+  var aside = slide ? slide.querySelector("aside.notes") : null;
+  notes.innerHTML = aside ? aside.innerHTML : "";
+}
+
+function updateTimer() {
+  var elapsed = Math.floor((new Date().getTime() - timerStart) / 1000);
+  var minutes = Math.floor(elapsed / 60);
+  var seconds = elapsed % 60;
+  document.getElementById("presenter-timer").textContent =
+    minutes + ":" + (seconds < 10 ? "0" : "") + seconds;
+}`
@@ -0,0 +1,365 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/tools/present"
+)
+
+// PDF pages have the size of slides, with one point per pixel, so that the
+// sizes below match those in styles.css.
+const (
+	pdfPageHeight = 700
+	pdfMarginY    = 40
+)
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+var pdfImageTypes = map[string]bool{"png": true, "jpg": true, "jpeg": true, "gif": true}
+
+// exportPDF sends the deck d for slideId as a PDF document with a page for
+// every slide. Speaker notes are never included.
+func exportPDF(w http.ResponseWriter, r *http.Request, slideId string, d *deck) {
+	var buf bytes.Buffer
+	if err := writePDF(&buf, slideId, d); err != nil {
+		internalError(w, slideId, "failed to export PDF", err)
+		return
+	}
+
+	title := strings.TrimSpace(d.doc.Title)
+	if title == "" {
+		title = "slides"
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": title + ".pdf"}))
+	setCacheControl(w, r, deckVersion(slideId))
+	w.Write(buf.Bytes())
+}
+
+// Font families used in PDF documents. They are the Go fonts unless the deck
+// ships TrueType fonts for its text or code font.
+const (
+	pdfFont     = "text"
+	pdfCodeFont = "code"
+)
+
+// pdfWriter lays out slides in embedded TrueType fonts, so that text in any
+// script their glyphs cover can be shown.
+type pdfWriter struct {
+	*fpdf.Fpdf
+	slideId string
+
+	pageWidth float64
+	marginX   float64
+	textWidth float64
+}
+
+func writePDF(w io.Writer, slideId string, d *deck) error {
+	doc := d.doc
+
+	pageWidth, marginX := 1100.0, 60.0
+	switch d.meta.Layout {
+	case layoutFauxWidescreen:
+		marginX = 160
+	case layoutStandard:
+		pageWidth = 900
+	}
+
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "pt",
+		Size:           fpdf.SizeType{Wd: pageWidth, Ht: pdfPageHeight},
+	})
+	pdf.SetTitle(doc.Title, true)
+	pdf.SetMargins(marginX, pdfMarginY, marginX)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetCellMargin(0)
+
+	p := &pdfWriter{
+		Fpdf:      pdf,
+		slideId:   slideId,
+		pageWidth: pageWidth,
+		marginX:   marginX,
+		textWidth: pageWidth - 2*marginX,
+	}
+	p.addFonts(d.meta)
+
+	p.AddPage()
+	p.SetY(pdfMarginY + 200)
+	p.heading(doc.Title, 60)
+	if doc.Subtitle != "" {
+		p.heading(doc.Subtitle, 30)
+	}
+	if !doc.Time.IsZero() {
+		p.heading(doc.Time.Format("2 January 2006"), 30)
+	}
+	for i := range doc.Authors {
+		p.Ln(20)
+		for _, e := range doc.Authors[i].TextElem() {
+			p.elem(e)
+		}
+	}
+
+	for _, s := range doc.Sections {
+		p.AddPage()
+		if len(s.Elem) == 0 {
+			p.SetY(pdfPageHeight - 150 - 45)
+			p.heading(s.Title, 45)
+			continue
+		}
+
+		p.heading(s.Title, 30)
+		for _, e := range s.Elem {
+			p.elem(e)
+		}
+	}
+
+	p.AddPage()
+	p.heading("Thank you", 30)
+	for i := range doc.Authors {
+		p.Ln(20)
+		for _, e := range doc.Authors[i].Elem {
+			p.elem(e)
+		}
+	}
+
+	return p.Output(w)
+}
+
+func (p *pdfWriter) elem(e present.Elem) {
+	switch e := e.(type) {
+	case present.Section:
+		p.Ln(20)
+		p.heading(e.Title, 26)
+		for _, e := range e.Elem {
+			p.elem(e)
+		}
+
+	case present.Text:
+		if e.Pre {
+			p.code(strings.Join(e.Lines, "\n"))
+			return
+		}
+
+		lines := make([]string, len(e.Lines))
+		for i, l := range e.Lines {
+			lines[i] = styledText(l)
+		}
+		p.paragraph(strings.Join(lines, "\n"))
+
+	case present.List:
+		p.Ln(20)
+		for _, b := range e.Bullet {
+			p.SetFont(pdfFont, "", 26)
+			p.SetTextColor(0, 0, 0)
+			p.SetX(p.marginX + 15)
+			p.CellFormat(24, 36, "•", "", 0, "L", false, 0, "")
+			p.MultiCell(p.textWidth-39, 36, styledText(b), "", "L", false)
+			p.Ln(13)
+		}
+
+	case present.Code:
+		p.code(htmlText(string(e.Text)))
+
+	case present.Image:
+		p.image(e)
+
+	case present.Iframe:
+		p.link(e.URL, e.URL)
+
+	case present.Link:
+		p.link(e.URL.String(), e.Label)
+
+	case present.HTML:
+		p.paragraph(htmlText(string(e.HTML)))
+
+	case present.Caption:
+		p.SetFont(pdfFont, "I", 18)
+		p.SetTextColor(102, 102, 102)
+		p.MultiCell(p.textWidth, 24, e.Text, "", "C", false)
+	}
+}
+
+// pdfFonts lists the styles of each font family used, with the Go font
+// for each.
+var pdfFonts = []struct {
+	family, style string
+	ttf           []byte
+}{
+	{pdfFont, "", goregular.TTF},
+	{pdfFont, "B", gobold.TTF},
+	{pdfFont, "I", goitalic.TTF},
+	{pdfCodeFont, "", gomono.TTF},
+}
+
+// addFonts embeds the fonts for the deck with meta. TrueType files the deck
+// uses for its text or code font replace the Go fonts, so that decks can
+// cover scripts the Go fonts don't. Styles the deck has no file for use its
+// regular font.
+func (p *pdfWriter) addFonts(meta deckMeta) {
+	deckFonts := map[string]map[string][]byte{pdfFont: {}, pdfCodeFont: {}}
+	for _, font := range meta.Fonts {
+		family := ""
+		switch {
+		case meta.Font != "" && font.Family == meta.Font:
+			family = pdfFont
+		case meta.CodeFont != "" && font.Family == meta.CodeFont:
+			family = pdfCodeFont
+		default:
+			continue
+		}
+
+		file, ok := deckFile(p.slideId, font.Src)
+		if !ok || !strings.EqualFold(path.Ext(font.Src), ".ttf") {
+			continue
+		}
+
+		if content, err := os.ReadFile(file); err == nil {
+			deckFonts[family][pdfFontStyle(font)] = content
+		}
+	}
+
+	for _, font := range pdfFonts {
+		ttf := deckFonts[font.family][font.style]
+		if ttf == nil {
+			ttf = deckFonts[font.family][""]
+		}
+
+		if ttf != nil {
+			p.AddUTF8FontFromBytes(font.family, font.style, ttf)
+			if p.Ok() {
+				continue
+			}
+
+			// Fall back to the Go font if the deck's can't be read.
+			p.ClearError()
+		}
+
+		p.AddUTF8FontFromBytes(font.family, font.style, font.ttf)
+	}
+}
+
+// pdfFontStyle returns the PDF style for font, counting weights from 600 as
+// bold.
+func pdfFontStyle(font deckFont) string {
+	style := ""
+	switch font.Weight {
+	case "bold", "600", "700", "800", "900":
+		style = "B"
+	}
+
+	if font.Style == "italic" {
+		style += "I"
+	}
+
+	return style
+}
+
+func (p *pdfWriter) heading(text string, size float64) {
+	p.SetFont(pdfFont, "B", size)
+	p.SetTextColor(51, 51, 51)
+	p.MultiCell(p.textWidth, size*1.2, text, "", "L", false)
+}
+
+func (p *pdfWriter) paragraph(text string) {
+	p.Ln(20)
+	p.SetFont(pdfFont, "", 26)
+	p.SetTextColor(0, 0, 0)
+	p.MultiCell(p.textWidth, 36, text, "", "L", false)
+}
+
+func (p *pdfWriter) code(text string) {
+	p.Ln(20)
+	p.SetFont(pdfCodeFont, "", 18)
+	p.SetTextColor(0, 0, 0)
+	p.SetFillColor(240, 240, 240)
+	p.SetDrawColor(224, 224, 224)
+	p.SetCellMargin(10)
+	p.MultiCell(p.textWidth, 24, strings.Replace(text, "\t", "    ", -1), "1", "L", true)
+	p.SetCellMargin(0)
+	p.Ln(20)
+}
+
+func (p *pdfWriter) link(url, label string) {
+	if label == "" {
+		label = url
+	}
+
+	p.Ln(20)
+	p.SetFont(pdfFont, "", 26)
+	p.SetTextColor(0, 102, 204)
+	p.SetX(p.marginX + 20)
+	p.CellFormat(p.textWidth-20, 36, label, "", 1, "L", false, 0, url)
+}
+
+// image draws an image from the deck directory, scaled down to fit the rest
+// of the page. Images in formats PDFs can't embed are replaced by their name.
+func (p *pdfWriter) image(img present.Image) {
+	file, ok := deckFile(p.slideId, img.URL)
+	imageType := strings.ToLower(strings.TrimPrefix(path.Ext(img.URL), "."))
+	if !ok || !pdfImageTypes[imageType] {
+		p.paragraph("[" + img.URL + "]")
+		return
+	}
+
+	opts := fpdf.ImageOptions{ImageType: imageType}
+	info := p.RegisterImageOptions(file, opts)
+	if !p.Ok() || info.Width() == 0 || info.Height() == 0 {
+		p.ClearError()
+		p.paragraph("[" + img.URL + "]")
+		return
+	}
+
+	w, h := float64(img.Width), float64(img.Height)
+	switch {
+	case w == 0 && h == 0:
+		w, h = info.Width(), info.Height()
+	case w == 0:
+		w = h * info.Width() / info.Height()
+	case h == 0:
+		h = w * info.Height() / info.Width()
+	}
+
+	y := p.GetY() + 40
+	maxH := pdfPageHeight - pdfMarginY - y
+	if maxH <= 0 {
+		return
+	}
+
+	if scale := math.Min(p.textWidth/w, maxH/h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+
+	p.ImageOptions(file, (p.pageWidth-w)/2, y, w, h, false, opts, 0, "")
+	p.SetY(y + h)
+}
+
+// styledText returns the text of a line of present markup, without its
+// font indicators.
+func styledText(s string) string {
+	return htmlText(string(present.Style(s)))
+}
+
+// htmlText returns the text content of the HTML fragment s.
+func htmlText(s string) string {
+	return html.UnescapeString(tagPattern.ReplaceAllString(s, ""))
+}
@@ -0,0 +1,211 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/webassembly"
+	"github.com/tetratelabs/wazero"
+)
+
+// Imported PDF pages are rendered at twice the size they are shown at, so
+// that they stay sharp on high density displays and projectors.
+const (
+	pdfRenderWidth  = 1960
+	pdfRenderHeight = 1120
+)
+
+var pdfMaxPages = flag.Int("pdf-max-pages", 300, "Maximum number of pages in an imported PDF deck")
+
+// pdfiumPool runs PDFium compiled to WebAssembly, so rendering needs neither
+// cgo nor external tools. It is started by the first PDF upload.
+var pdfiumPool struct {
+	once sync.Once
+	pool pdfium.Pool
+	err  error
+}
+
+// isPDF reports whether the uploaded file is a PDF document rather than a
+// slide archive.
+func isPDF(file multipart.File) bool {
+	magic := make([]byte, 5)
+	n, _ := file.ReadAt(magic, 0)
+	return string(magic[:n]) == "%PDF-"
+}
+
+// extractPDF stores the PDF document uploaded as name in the empty directory
+// slideBase and imports it as a deck.
+func extractPDF(slideBase, name string, file multipart.File) (warnings []string, err error) {
+	name = filepath.Base(filepath.Clean("/" + name))
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		name = "slides.pdf"
+	}
+
+	f, err := os.Create(filepath.Join(slideBase, name))
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	if _, err := io.Copy(f, file); err != nil {
+		return nil, err
+	}
+
+	entry, err := importPDF(slideBase, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeDeckEntry(slideBase, entry); err != nil {
+		return nil, err
+	}
+
+	return validateDeck(slideBase)
+}
+
+// importPDF renders every page of the PDF document name in deckDir to an
+// image and writes a slide file showing one page per slide, returning its
+// name. Both are written to a directory next to the document, named after it.
+// Documents that can't be rendered are reported as deckProblems.
+func importPDF(deckDir, name string) (entry string, err error) {
+	content, err := os.ReadFile(filepath.Join(deckDir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+
+	pdfiumPool.once.Do(func() {
+		pdfiumPool.pool, pdfiumPool.err = webassembly.Init(webassembly.Config{
+			MinIdle:  0,
+			MaxIdle:  1,
+			MaxTotal: 2,
+			// Documents are passed in memory, so no directory is mounted.
+			FSConfig: wazero.NewFSConfig(),
+			Stdout:   io.Discard,
+			Stderr:   io.Discard,
+		})
+	})
+
+	if pdfiumPool.err != nil {
+		return "", pdfiumPool.err
+	}
+
+	instance, err := pdfiumPool.pool.GetInstance(30 * time.Second)
+	if err != nil {
+		return "", err
+	}
+
+	defer instance.Close()
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{File: &content})
+	if err != nil {
+		return "", deckProblems{fmt.Sprintf("%s: cannot read PDF: %s", name, err)}
+	}
+
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
+
+	pages, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{Document: doc.Document})
+	if err != nil {
+		return "", deckProblems{fmt.Sprintf("%s: cannot read PDF: %s", name, err)}
+	}
+
+	switch {
+	case pages.PageCount == 0:
+		return "", deckProblems{fmt.Sprintf("%s: PDF has no pages", name)}
+	case pages.PageCount > *pdfMaxPages:
+		return "", deckProblems{fmt.Sprintf("%s: PDF has %d pages, at most %d are allowed", name, pages.PageCount, *pdfMaxPages)}
+	}
+
+	pagesDir := strings.TrimSuffix(name, path.Ext(name)) + "-pages"
+	if err := os.MkdirAll(filepath.Join(deckDir, filepath.FromSlash(pagesDir)), 0700); err != nil {
+		return "", err
+	}
+
+	var slide bytes.Buffer
+	title := slideLine(pdfMetaText(instance, doc.Document, "Title"))
+	if title == "" {
+		title = slideLine(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+	}
+	if title == "" {
+		title = "Slides"
+	}
+	fmt.Fprintf(&slide, "%s\n\n", title)
+	if author := slideLine(pdfMetaText(instance, doc.Document, "Author")); author != "" {
+		fmt.Fprintf(&slide, "%s\n\n", author)
+	}
+
+	for i := 0; i < pages.PageCount; i++ {
+		image := fmt.Sprintf("%03d.png", i+1)
+		width, height, err := renderPDFPage(instance, doc.Document, i, filepath.Join(deckDir, filepath.FromSlash(pagesDir), image))
+		if err != nil {
+			return "", deckProblems{fmt.Sprintf("%s: cannot render page %d: %s", name, i+1, err)}
+		}
+
+		// Slides have no title so that the page fills them.
+		fmt.Fprintf(&slide, "* \n\n.image %s %d %d\n\n", image, (height+1)/2, (width+1)/2)
+	}
+
+	entry = path.Join(pagesDir, "slides.slide")
+	return entry, os.WriteFile(filepath.Join(deckDir, filepath.FromSlash(entry)), slide.Bytes(), 0600)
+}
+
+// renderPDFPage renders page index of doc as a PNG image to file, returning
+// its size.
+func renderPDFPage(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, index int, file string) (width, height int, err error) {
+	page, err := instance.RenderPageInPixels(&requests.RenderPageInPixels{
+		Page:   requests.Page{ByIndex: &requests.PageByIndex{Document: doc, Index: index}},
+		Width:  pdfRenderWidth,
+		Height: pdfRenderHeight,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer page.Cleanup()
+
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer f.Close()
+
+	if err := png.Encode(f, page.Result.Image); err != nil {
+		return 0, 0, err
+	}
+
+	return page.Result.Width, page.Result.Height, f.Close()
+}
+
+// pdfMetaText returns the document information entry tag of doc.
+func pdfMetaText(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, tag string) string {
+	meta, err := instance.FPDF_GetMetaText(&requests.FPDF_GetMetaText{Document: doc, Tag: tag})
+	if err != nil {
+		return ""
+	}
+
+	return meta.Value
+}
+
+// slideLine returns s as a single line of text that present won't mistake
+// for a section heading or a command.
+func slideLine(s string) string {
+	return strings.TrimLeft(strings.Join(strings.Fields(s), " "), "*.#")
+}
@@ -0,0 +1,339 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	maxPlayOutput = 64 << 10
+	maxPlayBuild  = 64 << 20
+	maxPlayRuns   = 4
+
+	// maxPlayProcs limits the processes and threads of the snippet user,
+	// shared by all snippets being built and run.
+	maxPlayProcs = 256
+)
+
+var (
+	playEnabled = flag.Bool("play", false, "Run .play snippets for presenters in a sandboxed subprocess, which requires root")
+	playTimeout = flag.Duration("play-timeout", 10*time.Second, "Wall clock limit for building and running a snippet")
+	playCPU     = flag.Duration("play-cpu", 5*time.Second, "CPU time limit for building and running a snippet")
+	playMemory  = flag.Int("play-memory", 512, "Memory limit in MB for building and running a snippet")
+	playUid     = flag.Int("play-uid", 65534, "Unprivileged user ID that snippets are built and run as")
+	playGid     = flag.Int("play-gid", 65534, "Unprivileged group ID that snippets are built and run as")
+)
+
+// Snippets see a root directory holding only the Go installation, their own
+// directory and, while they are built, the build cache.
+const (
+	sandboxGoRoot = "/goroot"
+	sandboxDir    = "/play"
+	sandboxCache  = "/cache"
+)
+
+var (
+	errTooManyRuns = errors.New("Too many snippets running, try again shortly")
+	errBuildFailed = errors.New("build failed")
+)
+
+// playEnv holds what every snippet run uses: the directory snippets are built
+// in, the build cache shared by their builds, the directory their sandbox root
+// is mounted on and the Go installation. It is set up by the first run.
+var playEnv struct {
+	once   sync.Once
+	dir    string
+	cache  string
+	root   string
+	goRoot string
+	err    error
+}
+
+// playRuns limits the number of snippets built and run at the same time.
+var playRuns = make(chan struct{}, maxPlayRuns)
+
+// playMessage is exchanged as JSON over the presenter and viewer connections.
+// Presenters send "run" and "close" messages, and everyone is sent "start",
+// "stdout", "stderr", "system", "end" and "close" messages for the playground
+// at Index.
+type playMessage struct {
+	Kind  string
+	Index int
+	Body  string
+}
+
+// playSession runs the snippets sent by a presenter, one at a time.
+type playSession struct {
+	sync.Mutex
+	conn    *websocket.Conn
+	slideId string
+	cancel  context.CancelFunc
+}
+
+func (s *playSession) handle(data string) {
+	var msg playMessage
+	if err := json.Unmarshal([]byte(data), &msg); err != nil || !*playEnabled {
+		return
+	}
+
+	switch msg.Kind {
+	case "run":
+		s.stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *playTimeout)
+		s.Lock()
+		s.cancel = cancel
+		s.Unlock()
+
+		go func() {
+			defer cancel()
+			s.run(ctx, msg.Index, msg.Body)
+		}()
+
+	case "close":
+		s.stop()
+		s.send(playMessage{Kind: "close", Index: msg.Index})
+	}
+}
+
+// stop kills the running snippet, if any.
+func (s *playSession) stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// send delivers msg to the presenter and mirrors it to the deck's viewers.
+func (s *playSession) send(msg playMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	websocket.Message.Send(s.conn, string(data))
+	registry.sendMessage(s.slideId, string(data))
+}
+
+func (s *playSession) run(ctx context.Context, playground int, code string) {
+	s.send(playMessage{Kind: "start", Index: playground})
+
+	select {
+	case playRuns <- struct{}{}:
+		defer func() { <-playRuns }()
+	default:
+		s.send(playMessage{Kind: "end", Index: playground, Body: errTooManyRuns.Error()})
+		return
+	}
+
+	_, viewId := index.getIdPair(s.slideId)
+	slog.Info("running snippet", "view", viewId, "playground", playground)
+
+	err := runSnippet(ctx, code, func(kind, body string) {
+		s.send(playMessage{Kind: kind, Index: playground, Body: body})
+	})
+
+	result := "Program exited."
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result = "Program timed out."
+	case ctx.Err() == context.Canceled:
+		result = "Program killed."
+	case err == errBuildFailed:
+		result = "Build failed."
+	case err != nil:
+		if _, ok := err.(*exec.ExitError); ok {
+			result = "Program exited: " + err.Error() + "."
+		} else {
+			result = err.Error()
+		}
+	}
+
+	s.send(playMessage{Kind: "end", Index: playground, Body: result})
+}
+
+// runSnippet builds the Go program code and runs it in a sandbox, passing its
+// output to emit as it is produced.
+func runSnippet(ctx context.Context, code string, emit func(kind, body string)) error {
+	if err := setupPlayEnv(); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(playEnv.dir, "snippet-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	goMod := "module play\n"
+	if v := goVersion(); v != "" {
+		goMod += "\ngo " + v + "\n"
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0600); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(code), 0600); err != nil {
+		return err
+	}
+
+	// The go command ignores a go.mod in TMPDIR, so it gets its own.
+	if err := os.Mkdir(filepath.Join(dir, "tmp"), 0700); err != nil {
+		return err
+	}
+
+	for _, name := range []string{".", "go.mod", "main.go", "tmp"} {
+		if err := os.Chown(filepath.Join(dir, name), *playUid, *playGid); err != nil {
+			return err
+		}
+	}
+
+	// The build runs with the same limits as the program, as the compiler
+	// can be made to use unbounded memory and CPU time. It is the only step
+	// that can write to the build cache.
+	build, err := sandboxed(ctx, dir, playEnv.cache, maxPlayBuild,
+		[]string{"GOCACHE=" + sandboxCache, "CGO_ENABLED=0", "GOPROXY=off", "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local", "GOTELEMETRY=off"},
+		sandboxGoRoot+"/bin/go", "build", "-p", "2", "-o", "prog", ".")
+	if err != nil {
+		return err
+	}
+
+	if out, err := build.CombinedOutput(); err != nil {
+		if ctx.Err() == nil {
+			emit("stderr", strings.Replace(string(out), sandboxDir+"/", "", -1))
+		}
+		return errBuildFailed
+	}
+
+	run, err := sandboxed(ctx, dir, "", maxPlayOutput, []string{"GOCACHE=off"}, sandboxDir+"/prog")
+	if err != nil {
+		return err
+	}
+
+	stdout, err := run.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr, err := run.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := run.Start(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var written int64
+	var mu sync.Mutex
+	stream := func(kind string, r io.Reader) {
+		defer wg.Done()
+
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				written += int64(n)
+				tooMuch := written > maxPlayOutput
+				mu.Unlock()
+
+				if tooMuch {
+					emit("system", "Output limit exceeded.")
+					run.Cancel()
+					return
+				}
+				emit(kind, string(buf[:n]))
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	wg.Add(2)
+	go stream("stdout", stdout)
+	go stream("stderr", stderr)
+	wg.Wait()
+
+	return run.Wait()
+}
+
+// setupPlayEnv sets up playEnv the first time it is called.
+func setupPlayEnv() error {
+	playEnv.once.Do(func() {
+		playEnv.err = initPlayEnv()
+	})
+
+	return playEnv.err
+}
+
+// initPlayEnv creates the directories in playEnv, in a directory only the
+// server can enter, and finds the Go installation used to build snippets.
+func initPlayEnv() error {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(cacheDir, "rpresent-play")
+	playEnv.dir = filepath.Join(base, "snippets")
+	playEnv.cache = filepath.Join(base, "go-build")
+	playEnv.root = filepath.Join(base, "root")
+	for _, dir := range []string{base, playEnv.dir, playEnv.cache, playEnv.root} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Chmod(base, 0700); err != nil {
+		return err
+	}
+
+	if err := os.Chown(playEnv.cache, *playUid, *playGid); err != nil {
+		return err
+	}
+
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return fmt.Errorf("cannot find Go installation: %s", err)
+	}
+
+	playEnv.goRoot = strings.TrimSpace(string(out))
+	return nil
+}
+
+// goVersion returns the language version of the running Go release, e.g. 1.22.
+func goVersion() string {
+	v := strings.TrimPrefix(runtime.Version(), "go")
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + "." + parts[1]
+}
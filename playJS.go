@@ -0,0 +1,97 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+const playJS = `
+function getPlayground(index) {
+  return document.querySelectorAll("div.playground")[index];
+}
+
+function snippetText(playground) {
+  var text = "";
+  var pres = playground.getElementsByTagName("pre");
+  for(var i = 0; i < pres.length; i++) {
+    if(pres[i].parentNode.className != "output") {
+      text += pres[i].textContent;
+    }
+  }
+  return text;
+}
+
+function getOutput(playground, create) {
+  var output = playground.querySelector("div.output");
+  if(output || !create) {
+    return output;
+  }
+
+  output = document.createElement("div");
+  output.className = "output";
+  output.appendChild(document.createElement("pre"));
+
+  if(userRole == "p") {
+    var index = Array.prototype.indexOf.call(document.querySelectorAll("div.playground"), playground);
+    var buttons = document.createElement("div");
+    buttons.className = "buttons";
+    buttons.appendChild(makeButton("Close", function() {
+      sendPlay({Kind: "close", Index: index});
+    }));
+    output.appendChild(buttons);
+  }
+
+  playground.appendChild(output);
+  return output;
+}
+
+function makeButton(label, onClick) {
+  var button = document.createElement("button");
+  button.textContent = label;
+  button.addEventListener("click", onClick, false);
+  return button;
+}
+
+function handlePlayMessage(msg) {
+  var playground = getPlayground(msg.Index);
+  if(!playground) {
+    return;
+  }
+
+  if(msg.Kind == "close") {
+    var output = getOutput(playground, false);
+    if(output) {
+      output.parentNode.removeChild(output);
+    }
+    return;
+  }
+
+  var pre = getOutput(playground, true).querySelector("pre");
+  if(msg.Kind == "start") {
+    pre.textContent = "";
+    return;
+  }
+
+  var span = document.createElement("span");
+  span.className = msg.Kind == "end" ? "exit" : msg.Kind;
+  span.textContent = msg.Kind == "end" ? "\n" + msg.Body : msg.Body;
+  pre.appendChild(span);
+  pre.scrollTop = pre.scrollHeight;
+}
+
+document.addEventListener("DOMContentLoaded", function() {
+  if(userRole != "p") {
+    return;
+  }
+
+  var playgrounds = document.querySelectorAll("div.playground");
+  for(var i = 0; i < playgrounds.length; i++) {
+    (function(index, playground) {
+      var buttons = document.createElement("div");
+      buttons.className = "buttons";
+      buttons.appendChild(makeButton("Run", function() {
+        sendPlay({Kind: "run", Index: index, Body: snippetText(playground)});
+      }));
+      playground.parentNode.insertBefore(buttons, playground.nextSibling);
+    })(i, playgrounds[i]);
+  }
+});`
@@ -0,0 +1,217 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxArg0 is the name the server runs itself under to set up a sandbox
+// for a snippet command.
+const sandboxArg0 = "rpresent-sandbox"
+
+func init() {
+	if len(os.Args) == 2 && os.Args[0] == sandboxArg0 {
+		err := enterSandbox(os.Args[1])
+		fmt.Fprintln(os.Stderr, "sandbox:", err)
+		os.Exit(126)
+	}
+}
+
+// sandboxSpec describes a snippet command and the sandbox it runs in. It is
+// passed as JSON to the server process that sets up the sandbox.
+type sandboxSpec struct {
+	Root   string
+	GoRoot string
+	Dir    string
+	Cache  string
+	Uid    int
+	Gid    int
+	Limits map[int]uint64
+	Env    []string
+	Args   []string
+}
+
+// bindMount mounts source at target in the sandbox root, with flags.
+type bindMount struct {
+	source, target string
+	flags          uintptr
+}
+
+// sandboxed returns a command running args in a sandbox for the snippet in
+// dir, with the snippet resource limits and writing files of at most maxFile
+// bytes. The command can write to the build cache only if cache is set. Its
+// environment is env and the sandbox paths.
+func sandboxed(ctx context.Context, dir, cache string, maxFile int, env []string, args ...string) (*exec.Cmd, error) {
+	spec, err := json.Marshal(sandboxSpec{
+		Root:   playEnv.root,
+		GoRoot: playEnv.goRoot,
+		Dir:    dir,
+		Cache:  cache,
+		Uid:    *playUid,
+		Gid:    *playGid,
+		Limits: map[int]uint64{
+			unix.RLIMIT_CPU:   uint64(playCPU.Seconds() + 0.5),
+			unix.RLIMIT_DATA:  uint64(*playMemory) << 20,
+			unix.RLIMIT_FSIZE: uint64(maxFile),
+			unix.RLIMIT_NPROC: maxPlayProcs,
+		},
+		Env: append([]string{
+			"HOME=" + sandboxDir,
+			"TMPDIR=" + sandboxDir + "/tmp",
+			"PATH=" + sandboxGoRoot + "/bin",
+			"GOROOT=" + sandboxGoRoot,
+			// Go programs start a thread per CPU, which counts against
+			// maxPlayProcs.
+			"GOMAXPROCS=2",
+		}, env...),
+		Args: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", string(spec))
+	cmd.Args[0] = sandboxArg0
+	cmd.Env = []string{}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	return cmd, nil
+}
+
+// enterSandbox runs in the new namespaces of a sandboxed command. It makes a
+// read-only root holding only the Go installation, the snippet directory and
+// the build cache if there is one, switches to the snippet user and replaces
+// itself with the command. It only returns on failure.
+func enterSandbox(data string) error {
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return err
+	}
+
+	// Keep the mounts below from propagating to the server's namespace.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("tmpfs", spec.Root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755,size=64k"); err != nil {
+		return err
+	}
+
+	binds := []bindMount{
+		{spec.GoRoot, sandboxGoRoot, unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV},
+		{spec.Dir, sandboxDir, unix.MS_NOSUID | unix.MS_NODEV},
+		{"/dev/null", "/dev/null", unix.MS_NOSUID | unix.MS_NOEXEC},
+		{"/dev/zero", "/dev/zero", unix.MS_NOSUID | unix.MS_NOEXEC},
+		{"/dev/urandom", "/dev/urandom", unix.MS_NOSUID | unix.MS_NOEXEC},
+	}
+	if spec.Cache != "" {
+		binds = append(binds, bindMount{spec.Cache, sandboxCache, unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC})
+	}
+
+	for _, b := range binds {
+		target := filepath.Join(spec.Root, b.target)
+		if err := mountPoint(b.source, target); err != nil {
+			return err
+		}
+
+		if err := unix.Mount(b.source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return err
+		}
+
+		if err := unix.Mount("", target, "", unix.MS_REMOUNT|unix.MS_BIND|b.flags, ""); err != nil {
+			return err
+		}
+	}
+
+	old := filepath.Join(spec.Root, ".old")
+	if err := os.Mkdir(old, 0700); err != nil {
+		return err
+	}
+
+	if err := unix.PivotRoot(spec.Root, old); err != nil {
+		return err
+	}
+
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+
+	if err := unix.Unmount("/.old", unix.MNT_DETACH); err != nil {
+		return err
+	}
+
+	if err := os.Remove("/.old"); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return err
+	}
+
+	if err := unix.Chdir(sandboxDir); err != nil {
+		return err
+	}
+
+	for resource, limit := range spec.Limits {
+		if err := unix.Setrlimit(resource, &unix.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Setgroups(nil); err != nil {
+		return err
+	}
+
+	if err := unix.Setresgid(spec.Gid, spec.Gid, spec.Gid); err != nil {
+		return err
+	}
+
+	if err := unix.Setresuid(spec.Uid, spec.Uid, spec.Uid); err != nil {
+		return err
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+
+	return unix.Exec(spec.Args[0], spec.Args, spec.Env)
+}
+
+// mountPoint creates target to mount source on, as a directory or an empty
+// file to match source.
+func mountPoint(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(target, nil, 0644)
+}
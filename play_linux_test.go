@@ -0,0 +1,67 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sandboxSnippet = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	for _, name := range []string{%q, %q} {
+		if _, err := os.Stat(name); err == nil {
+			fmt.Println("found", name)
+		}
+	}
+	fmt.Println("done")
+}
+`
+
+func TestSnippetSandbox(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("running snippets requires root")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir := useSlidesDir(t)
+	writeFiles(t, dir, map[string]string{"index.json": "{}"})
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// Builds with an empty cache compile the standard library.
+	oldCPU := *playCPU
+	*playCPU = time.Minute
+	t.Cleanup(func() { *playCPU = oldCPU })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var out strings.Builder
+	code := fmt.Sprintf(sandboxSnippet, dir, filepath.Join(dir, "index.json"))
+	err := runSnippet(ctx, code, func(kind, body string) {
+		out.WriteString(body)
+	})
+	if err != nil {
+		t.Fatalf("runSnippet: %v\n%s", err, out.String())
+	}
+
+	if got := out.String(); got != "done\n" {
+		t.Errorf("snippet could see the slides directory:\n%s", got)
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// sandboxed is only implemented on Linux, which provides the namespaces that
+// isolate snippets from the server and the network.
+func sandboxed(ctx context.Context, dir, cache string, maxFile int, env []string, args ...string) (*exec.Cmd, error) {
+	return nil, errors.New("Running snippets is only supported on Linux.")
+}
@@ -0,0 +1,93 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+// printCSS lays out all slides one below the other, one slide per page. It is
+// used when printing a deck and by the handout page.
+const printCSS = `
+@page {
+  size: A4 landscape;
+  margin: 10mm;
+}
+
+body {
+  height: auto;
+  min-height: 0;
+  overflow: visible;
+  background: white;
+}
+
+.slides {
+  position: static;
+  -webkit-transform: none;
+}
+
+.slides > article,
+.slides.layout-widescreen > article,
+.slides.layout-faux-widescreen > article {
+  display: block !important;
+  position: relative;
+
+  left: auto;
+  top: auto;
+  margin: 20px auto;
+
+  transform: none !important;
+  -o-transform: none !important;
+  -moz-transform: none !important;
+  -webkit-transform: none !important;
+  transition: none;
+  -o-transition: none;
+  -moz-transition: none;
+  -webkit-transition: none;
+
+  page-break-inside: avoid;
+  page-break-after: always;
+}
+
+.slides.handout > article {
+  height: auto;
+  min-height: 700px;
+}
+.slides.handout > article > aside.notes {
+  display: block;
+
+  margin-top: 40px;
+  padding-top: 20px;
+  border-top: 1px solid rgb(224, 224, 224);
+
+  font-size: 20px;
+  line-height: 28px;
+  letter-spacing: 0;
+  color: rgb(102, 102, 102);
+}
+
+.slide-area,
+.helpLink,
+#toc,
+.buttons,
+div.output {
+  display: none;
+}
+
+@media print {
+  body {
+    display: block !important;
+  }
+
+  .slides > article,
+  .slides.layout-widescreen > article,
+  .slides.layout-faux-widescreen > article {
+    margin: 0 auto;
+    border: none;
+  }
+
+  /* Add explicit links */
+  a:link:after,
+  a:visited:after {
+    content: ' (' attr(href) ') ';
+    font-size: 50%;
+  }
+}`
@@ -6,36 +6,64 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"code.google.com/p/go.net/websocket"
+	"golang.org/x/net/websocket"
 )
 
-var registry = &listenerRegistry{listeners: make(map[string][]*slideListener)}
+var registry = &listenerRegistry{
+	listeners:  make(map[string][]*slideListener),
+	presenters: make(map[string]int),
+	changed:    make(map[string]time.Time),
+	conns:      make(map[*websocket.Conn]bool),
+}
 
 func handlePresenter(conn *websocket.Conn) {
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 	var slideIdParam string
 	if err := websocket.Message.Receive(conn, &slideIdParam); err != nil {
+		disconnectsTotal.inc("presenter", disconnectReason(err))
 		return
 	}
 
-	slideId, _ := index.getIdPair(slideIdParam)
+	slideId, viewId := index.getIdPair(slideIdParam)
 	if slideId == "" {
+		disconnectsTotal.inc("presenter", "invalid_id")
 		return
 	}
 
+	registry.addConn(conn)
+	defer registry.removeConn(conn)
+
+	registry.addPresenter(slideId)
+	defer registry.removePresenter(slideId)
+
+	slog.Info("presenter connected", "view", viewId, "remote", conn.Request().RemoteAddr)
+	defer slog.Info("presenter disconnected", "view", viewId)
+
+	play := &playSession{conn: conn, slideId: slideId}
+	defer play.stop()
+
 	for {
 		var slide string
 
 		conn.SetDeadline(time.Now().Add(15 * time.Minute))
 		if err := websocket.Message.Receive(conn, &slide); err != nil {
+			disconnectsTotal.inc("presenter", disconnectReason(err))
 			return
 		}
 
+		if strings.HasPrefix(slide, "{") {
+			play.handle(slide)
+			continue
+		}
+
 		curSlide, _ := strconv.Atoi(slide)
+		slog.Debug("slide changed", "view", viewId, "slide", curSlide)
 		registry.setSlide(slideId, curSlide)
 	}
 }
@@ -44,30 +72,57 @@ func handleViewer(conn *websocket.Conn) {
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 	var viewId string
 	if err := websocket.Message.Receive(conn, &viewId); err != nil {
+		disconnectsTotal.inc("viewer", disconnectReason(err))
 		return
 	}
 
 	slideId := index.getSlideId(viewId)
 	if slideId == "" {
+		disconnectsTotal.inc("viewer", "invalid_id")
 		return
 	}
 
-	listener := &slideListener{ch: make(chan int)}
+	// Viewers may connect using the presenter ID, which must not be logged.
+	_, viewId = index.getIdPair(slideId)
+
+	registry.addConn(conn)
+	defer registry.removeConn(conn)
+
+	listener := &slideListener{ch: make(chan int), msgs: make(chan string, 64)}
 	registry.addListener(slideId, listener)
 
+	slog.Info("viewer connected", "view", viewId, "viewers", registry.count(slideId))
+	defer func() {
+		slog.Info("viewer disconnected", "view", viewId, "viewers", registry.count(slideId))
+	}()
+
 	for {
-		slide := listener.get(1 * time.Minute)
+		slide, msg := listener.get(1 * time.Minute)
+		if msg != "" {
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			if err := websocket.Message.Send(conn, msg); err != nil {
+				disconnectsTotal.inc("viewer", disconnectReason(err))
+				registry.removeListener(slideId, listener)
+				return
+			}
+
+			continue
+		}
+
 		if slide != 0 {
 			conn.SetDeadline(time.Now().Add(10 * time.Second))
 			if err := websocket.Message.Send(conn, fmt.Sprintf("%d", slide)); err != nil {
+				disconnectsTotal.inc("viewer", disconnectReason(err))
 				registry.removeListener(slideId, listener)
 				return
 			}
 
+			fanoutSeconds.observe(time.Since(registry.changedAt(slideId)))
 			continue
 		}
 
 		if err := ping(conn); err != nil {
+			disconnectsTotal.inc("viewer", disconnectReason(err))
 			registry.removeListener(slideId, listener)
 			return
 		}
@@ -92,6 +147,7 @@ type slideListener struct {
 	sync.Mutex
 	slide int
 	ch    chan int
+	msgs  chan string
 }
 
 func (l *slideListener) set(slide int) {
@@ -104,29 +160,46 @@ func (l *slideListener) set(slide int) {
 	}
 }
 
-func (l *slideListener) get(timeout time.Duration) int {
+// send queues msg for the listener, dropping it if the listener is too far
+// behind.
+func (l *slideListener) send(msg string) {
+	select {
+	case l.msgs <- msg:
+	default:
+	}
+}
+
+// get waits for the next slide change or message, returning neither if none
+// arrives within timeout.
+func (l *slideListener) get(timeout time.Duration) (int, string) {
 	l.Lock()
 	curSlide := l.slide
 	l.slide = 0
 	l.Unlock()
 
 	if curSlide != 0 {
-		return curSlide
+		return curSlide, ""
 	}
 
 	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
 	select {
 	case slide := <-l.ch:
-		timer.Stop()
-		return slide
+		return slide, ""
+	case msg := <-l.msgs:
+		return 0, msg
 	case <-timer.C:
-		return 0
+		return 0, ""
 	}
 }
 
 type listenerRegistry struct {
 	sync.Mutex
-	listeners map[string][]*slideListener
+	listeners  map[string][]*slideListener
+	presenters map[string]int
+	changed    map[string]time.Time
+	conns      map[*websocket.Conn]bool
 }
 
 func (r *listenerRegistry) addListener(slideId string, listener *slideListener) {
@@ -152,11 +225,107 @@ func (r *listenerRegistry) removeListener(slideId string, listener *slideListene
 	r.listeners[slideId] = listeners
 }
 
+func (r *listenerRegistry) count(slideId string) int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.listeners[slideId])
+}
+
+func (r *listenerRegistry) addPresenter(slideId string) {
+	r.Lock()
+	defer r.Unlock()
+	r.presenters[slideId]++
+}
+
+func (r *listenerRegistry) removePresenter(slideId string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.presenters[slideId]--
+	if r.presenters[slideId] <= 0 {
+		delete(r.presenters, slideId)
+	}
+}
+
+// counts returns the number of connected presenters and viewers by slide ID.
+func (r *listenerRegistry) counts() (presenters, viewers map[string]int) {
+	r.Lock()
+	defer r.Unlock()
+
+	presenters, viewers = make(map[string]int), make(map[string]int)
+	for slideId, count := range r.presenters {
+		presenters[slideId] = count
+	}
+	for slideId, listeners := range r.listeners {
+		if len(listeners) > 0 {
+			viewers[slideId] = len(listeners)
+		}
+	}
+
+	return presenters, viewers
+}
+
+func (r *listenerRegistry) changedAt(slideId string) time.Time {
+	r.Lock()
+	defer r.Unlock()
+	return r.changed[slideId]
+}
+
 func (r *listenerRegistry) setSlide(slideId string, slide int) {
 	r.Lock()
 	defer r.Unlock()
 
+	r.changed[slideId] = time.Now()
+
 	for _, listener := range r.listeners[slideId] {
 		listener.set(slide)
 	}
 }
+
+// sendMessage sends msg to every viewer of slideId.
+func (r *listenerRegistry) sendMessage(slideId string, msg string) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, listener := range r.listeners[slideId] {
+		listener.send(msg)
+	}
+}
+
+func (r *listenerRegistry) addConn(conn *websocket.Conn) {
+	r.Lock()
+	defer r.Unlock()
+	r.conns[conn] = true
+}
+
+func (r *listenerRegistry) removeConn(conn *websocket.Conn) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.conns, conn)
+}
+
+// broadcast sends msg to every connected presenter and viewer.
+func (r *listenerRegistry) broadcast(msg string) {
+	r.Lock()
+	defer r.Unlock()
+
+	var wg sync.WaitGroup
+	for conn := range r.conns {
+		wg.Add(1)
+		go func(conn *websocket.Conn) {
+			defer wg.Done()
+			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			websocket.Message.Send(conn, msg)
+		}(conn)
+	}
+	wg.Wait()
+}
+
+func (r *listenerRegistry) closeAll() {
+	r.Lock()
+	defer r.Unlock()
+
+	for conn := range r.conns {
+		conn.Close()
+	}
+}
@@ -5,10 +5,21 @@
 package main
 
 const remoteJS = `
+var config = document.querySelector("meta[name='rpresent']");
+var rSlideId = config.getAttribute("data-slide-id");
+var userRole = config.getAttribute("data-role");
+var pathPrefix = config.getAttribute("data-path-prefix");
+var staticVersion = config.getAttribute("data-static-version");
+var themeURLs = config.getAttribute("data-themes");
+
 var remotePaused = false;
-var path = window.location.pathname;
-var wsURL = "ws://" + window.location.host +  path.substring(0, path.lastIndexOf('/')) + "/" + userRole;
-var ws = new WebSocket(wsURL);
+var wsScheme = window.location.protocol == "https:" ? "wss://" : "ws://";
+var wsURL = wsScheme + window.location.host + pathPrefix + "/" + userRole;
+var ws;
+var serverRestarting = false;
+var RECONNECT_DELAY = 2000;
+
+connectRemote();
 
 document.addEventListener("DOMContentLoaded", function() {
   if(userRole != "v") {
@@ -28,27 +39,61 @@ document.addEventListener("DOMContentLoaded", function() {
   });
 });
 
-ws.onmessage = function(event) {
-  if(event.data == "ping") {
-    ws.send("pong");
-    return;
+function connectRemote() {
+  ws = new WebSocket(wsURL);
+
+  ws.onmessage = function(event) {
+    if(event.data == "ping") {
+      ws.send("pong");
+      return;
+    }
+
+    if(event.data.charAt(0) == "{") {
+      if(typeof handlePlayMessage !== "undefined") {
+        handlePlayMessage(JSON.parse(event.data));
+      }
+      return;
+    }
+
+    if(event.data == "restarting") {
+      serverRestarting = true;
+      document.title += " [SERVER RESTARTING]";
+      return;
+    }
+
+    if(userRole == "v" && !remotePaused) {
+      curSlide = Number(event.data) - 1;
+      updateSlides();
+    }
   }
 
-  if(userRole == "v" && !remotePaused) {
-    curSlide = Number(event.data) - 1;
-    updateSlides();
+  ws.onopen = function(event) {
+    if(serverRestarting) {
+      serverRestarting = false;
+      document.title = document.title.replace(" [SERVER RESTARTING]", "");
+    }
+
+    ws.send(rSlideId);
+    if(userRole == "p") {
+      ws.send(curSlide + 1);
+    }
   }
-} 
 
-ws.onopen = function(event) {
-  ws.send(rSlideId);
-  if(userRole == "p") {
-	  ws.send(curSlide + 1);
+  ws.onclose = function(event) {
+    if(serverRestarting) {
+      window.setTimeout(connectRemote, RECONNECT_DELAY);
+    }
+  }
+}
+
+function sendPlay(msg) {
+  if(userRole == "p" && ws.readyState == WebSocket.OPEN) {
+    ws.send(JSON.stringify(msg));
   }
 }
 
 function sendRemote(curSlide) {
-  if(userRole == "p") {
+  if(userRole == "p" && ws.readyState == WebSocket.OPEN) {
     ws.send(curSlide+1 + "");
   }
 }`
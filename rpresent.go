@@ -6,59 +6,159 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"code.google.com/p/go.net/websocket"
+	"golang.org/x/net/websocket"
+	"golang.org/x/tools/present"
 )
 
 var (
 	httpAddr  = flag.String("http", ":8080", "HTTP address to listen on")
 	slidesDir = flag.String("d", "slides", "Directory to store slides in")
-	baseURL   = flag.String("b", "http://localhost:8080", "Base URL for slides")
+	baseURL   = flag.String("b", "", "Base URL for slides; derived from each request when empty")
+
+	pathPrefix     = flag.String("prefix", "", "Path prefix the app is mounted under, e.g. /talks")
+	trustedProxies = flag.String("trusted-proxies", "", "Comma separated IPs/CIDRs of proxies whose X-Forwarded-* headers are trusted")
+
+	tlsCert      = flag.String("tls-cert", "", "TLS certificate file; serves HTTPS when set with -tls-key")
+	tlsKey       = flag.String("tls-key", "", "TLS private key file")
+	redirectAddr = flag.String("https-redirect", "", "HTTP address to listen on for redirecting to HTTPS")
+
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "Time to wait for in-flight requests on shutdown")
 )
 
 func main() {
 	flag.Parse()
 
+	if err := loadConfig(); err != nil {
+		slog.Error("invalid configuration", "err", err)
+		os.Exit(1)
+	}
+
+	if err := setupLogging(); err != nil {
+		slog.Error("invalid configuration", "err", err)
+		os.Exit(1)
+	}
+
+	present.PlayEnabled = *playEnabled
+
+	if args := flag.Args(); len(args) > 0 {
+		if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+			if err := printConfig(os.Stdout); err != nil {
+				slog.Error("failed to print configuration", "err", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		slog.Error("unknown command", "command", strings.Join(args, " "))
+		os.Exit(1)
+	}
+
+	useTLS := *tlsCert != ""
+
+	*pathPrefix = normalizePrefix(*pathPrefix)
+
+	var err error
+	if trustedNets, err = parseTrustedProxies(*trustedProxies); err != nil {
+		slog.Error("invalid configuration", "err", err)
+		os.Exit(1)
+	}
+
 	if err := os.MkdirAll(*slidesDir, 0700); err != nil {
-		log.Fatalln("Failed to create slides directory:", err)
+		slog.Error("failed to create slides directory", "err", err)
+		os.Exit(1)
 	}
 
 	if err := index.load(filepath.Join(*slidesDir, "index.json")); err != nil {
-		log.Fatalln("Failed to load index:", err)
+		slog.Error("failed to load index", "err", err)
+		os.Exit(1)
 	}
 
 	rand.Seed(time.Now().UnixNano())
 
-	http.HandleFunc("/", handleRoot)
-	http.HandleFunc("/help", help)
-	http.HandleFunc("/static/", statics)
-	http.HandleFunc("/res/", slideResource)
-	http.Handle("/p", websocket.Handler(handlePresenter))
-	http.Handle("/v", websocket.Handler(handleViewer))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleRoot)
+	mux.HandleFunc("/help", help)
+	mux.HandleFunc("/static/", statics)
+	mux.HandleFunc("/res/", slideResource)
+	mux.HandleFunc("/metrics", metrics)
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz)
+	mux.Handle("/p", websocket.Handler(handlePresenter))
+	mux.Handle("/v", websocket.Handler(handleViewer))
+
+	handler := http.Handler(mux)
+	if *pathPrefix != "" {
+		root := http.NewServeMux()
+		root.Handle(*pathPrefix+"/", http.StripPrefix(*pathPrefix, mux))
+		handler = root
+	}
+
+	handler = logRequests(securityHeaders(handler))
+
+	server := &http.Server{Addr: *httpAddr, Handler: handler}
+	servers := []*http.Server{server}
 
-	fmt.Println("Listening at", *httpAddr)
-	http.ListenAndServe(*httpAddr, nil)
+	if *redirectAddr != "" {
+		redirectServer := &http.Server{Addr: *redirectAddr, Handler: http.HandlerFunc(redirectToHTTPS)}
+		servers = append(servers, redirectServer)
+
+		go func() {
+			slog.Info("redirecting to HTTPS", "addr", *redirectAddr)
+			if err := redirectServer.ListenAndServe(); err != http.ErrServerClosed {
+				slog.Error("failed to start redirect server", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	shutdownDone := make(chan struct{})
+	go awaitShutdown(shutdownDone, servers...)
+
+	slog.Info("listening", "addr", *httpAddr)
+
+	if useTLS {
+		err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		slog.Error("failed to start server", "err", err)
+		os.Exit(1)
+	}
+
+	<-shutdownDone
 }
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
 		if r.URL.Path == "/" {
-			uploadTmpl.Execute(w, nil)
+			uploadTmpl.Execute(w, map[string]string{"pathPrefix": *pathPrefix})
 		} else {
 			presentSlide(w, r)
 		}
 
 	case "POST":
+		if isShuttingDown() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			msgTmpl.Execute(w, map[string]interface{}{
+				"title": "Server Restarting",
+				"msg":   "Server is restarting, please try again shortly",
+				"error": true,
+			})
+			return
+		}
+
 		processUpload(w, r)
 
 	default:
@@ -66,6 +166,19 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	if _, port, err := net.SplitHostPort(*httpAddr); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
 func help(w http.ResponseWriter, r *http.Request) {
 	helpTmpl.Execute(w, nil)
 }
@@ -73,19 +186,13 @@ func help(w http.ResponseWriter, r *http.Request) {
 func statics(w http.ResponseWriter, r *http.Request) {
 	parts := strings.SplitN(r.URL.Path, "/", 3)
 
-	switch parts[2] {
-	case "slides.js":
-		w.Header()["Content-Type"] = []string{"application/javascript"}
-		w.Write([]byte(slidesJS))
-	case "remote.js":
-		w.Header()["Content-Type"] = []string{"application/javascript"}
-		w.Write([]byte(remoteJS))
-	case "styles.css":
-		w.Header()["Content-Type"] = []string{"text/css"}
-		w.Write([]byte(stylesCSS))
-	default:
+	asset, ok := staticAssets[parts[2]]
+	if !ok {
 		http.NotFound(w, r)
+		return
 	}
+
+	asset.serve(w, r)
 }
 
 var helpTmpl = template.Must(template.New("share").Parse(`
@@ -107,6 +214,7 @@ var helpTmpl = template.Must(template.New("share").Parse(`
 	<ul>
 		<li>Arrows - Navigate slides</li>
 		<li>Pause - Pause/Resume presenter's remote control. Can be useful to review slides during presentation without being dragged back by the presenter.</li>
+		<li>T - Show/Hide the table of contents. Once the remote control is paused, pick a slide in it to jump there.</li>
 	</ul>
 </body>
 </html>`))
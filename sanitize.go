@@ -0,0 +1,278 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+)
+
+const (
+	htmlAllow    = "allow"
+	htmlSanitize = "sanitize"
+	htmlReject   = "reject"
+)
+
+var htmlMode = flag.String("html", htmlAllow, "Raw HTML in uploaded decks: allow, sanitize or reject")
+
+// linkPattern matches the URLs of inline links and of .link and .iframe
+// commands.
+var linkPattern = regexp.MustCompile(`\[\[\s*([^\]]+)\]|^\.(?:link|iframe)\s+(\S+)`)
+
+// deckProblems lists everything wrong with an uploaded deck, one problem per
+// line of the slide file.
+type deckProblems []string
+
+func (p deckProblems) Error() string {
+	return strings.Join(p, "\n")
+}
+
+var allowedTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "blockquote": true, "br": true,
+	"caption": true, "cite": true, "code": true, "col": true, "colgroup": true,
+	"dd": true, "del": true, "div": true, "dl": true, "dt": true, "em": true,
+	"figcaption": true, "figure": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "hr": true, "i": true, "img": true,
+	"ins": true, "kbd": true, "li": true, "mark": true, "ol": true, "p": true,
+	"pre": true, "q": true, "s": true, "samp": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "table": true, "tbody": true,
+	"td": true, "tfoot": true, "th": true, "thead": true, "tr": true, "u": true,
+	"ul": true, "var": true,
+}
+
+// allowedAttrs leaves out style, which would let a deck cover the page with
+// content of its own.
+var allowedAttrs = map[string]bool{
+	"alt": true, "class": true, "colspan": true, "height": true, "href": true,
+	"id": true, "rowspan": true, "src": true, "title": true, "width": true,
+}
+
+// droppedContent lists elements whose content is removed along with them.
+var droppedContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "template": true, "noscript": true,
+}
+
+// checkDeckHTML enforces htmlMode on the slide file extracted to deckDir,
+// sanitizing the files included by .html commands or reporting them.
+func checkDeckHTML(deckDir string) error {
+	if *htmlMode == htmlAllow {
+		return nil
+	}
+
+	source := deckSource(deckDir)
+	file, ok := confinedPath(deckDir, source)
+	if !ok {
+		return deckProblems{fmt.Sprintf("%s: file must be inside the archive", source)}
+	}
+
+	if isMarkdown(source) {
+		return checkMarkdownHTML(file, source)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	var problems deckProblems
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+
+		for _, m := range linkPattern.FindAllStringSubmatch(line, -1) {
+			if link := m[1] + m[2]; !safeURL(link) {
+				problems = append(problems, fmt.Sprintf("%s:%d: unsafe link scheme: %s", source, lineno, urlScheme(link)))
+			}
+		}
+
+		args := strings.Fields(line)
+		if !strings.HasPrefix(line, ".html") || len(args) != 2 || args[0] != ".html" {
+			continue
+		}
+
+		if *htmlMode == htmlReject {
+			problems = append(problems, fmt.Sprintf("%s:%d: raw HTML is not allowed: %s", source, lineno, line))
+			continue
+		}
+
+		htmlFile, ok := confinedPath(deckDir, path.Join(path.Dir(source), args[1]))
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s:%d: HTML file must be inside the archive: %s", source, lineno, args[1]))
+			continue
+		}
+
+		if err := sanitizeHTMLFile(htmlFile); err != nil {
+			problems = append(problems, fmt.Sprintf("%s:%d: %s", source, lineno, err))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// checkMarkdownHTML reports raw HTML in the Markdown slide file source when
+// htmlMode rejects it, and links with unsafe schemes. Raw HTML is sanitized when the
+// deck is rendered.
+func checkMarkdownHTML(file, source string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var problems deckProblems
+	doc := markdown.Parser().Parse(text.NewReader(content))
+	err = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		lineno := markdownLine(content, n)
+		switch n := n.(type) {
+		case *ast.HTMLBlock, *ast.RawHTML:
+			if *htmlMode == htmlReject {
+				problems = append(problems, fmt.Sprintf("%s:%d: raw HTML is not allowed", source, lineno))
+			}
+		case *ast.Link:
+			if link := string(n.Destination); !safeURL(link) {
+				problems = append(problems, fmt.Sprintf("%s:%d: unsafe link scheme: %s", source, lineno, urlScheme(link)))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func sanitizeHTMLFile(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("cannot read HTML file: %s", filepath.Base(file))
+	}
+
+	var buf bytes.Buffer
+	if err := sanitizeHTML(&buf, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0600)
+}
+
+// sanitizeHTML copies the HTML read from r to w, keeping only allowed tags and
+// attributes and dropping URLs with schemes other than http, https and mailto.
+func sanitizeHTML(w io.Writer, r io.Reader) error {
+	z := html.NewTokenizer(r)
+	dropDepth := 0
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return nil
+			}
+			return z.Err()
+
+		case html.TextToken:
+			if dropDepth == 0 {
+				io.WriteString(w, html.EscapeString(string(z.Text())))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedContent[tok.Data] {
+				if tok.Type == html.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+
+			if dropDepth == 0 && allowedTags[tok.Data] {
+				tok.Attr = sanitizeAttrs(tok.Attr)
+				io.WriteString(w, tok.String())
+			}
+
+		case html.EndTagToken:
+			tok := z.Token()
+			if droppedContent[tok.Data] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+
+			if dropDepth == 0 && allowedTags[tok.Data] {
+				io.WriteString(w, tok.String())
+			}
+		}
+	}
+}
+
+func sanitizeAttrs(attrs []html.Attribute) []html.Attribute {
+	var safe []html.Attribute
+	for _, attr := range attrs {
+		if attr.Namespace != "" || !allowedAttrs[attr.Key] {
+			continue
+		}
+
+		if (attr.Key == "href" || attr.Key == "src") && !safeURL(attr.Val) {
+			continue
+		}
+
+		safe = append(safe, attr)
+	}
+
+	return safe
+}
+
+func safeURL(rawURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// urlScheme returns the scheme of rawURL, which safeURL rejected, to report
+// it. URLs that can't be parsed are returned whole.
+func urlScheme(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if i := strings.IndexByte(rawURL, ':'); i > 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
@@ -0,0 +1,112 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{`<p>Hello <b>world</b></p>`, `<p>Hello <b>world</b></p>`},
+		{`<p>a &lt; b</p>`, `<p>a &lt; b</p>`},
+		{`<br/>`, `<br/>`},
+		{`<table><tr><td colspan="2">x</td></tr></table>`, `<table><tr><td colspan="2">x</td></tr></table>`},
+
+		{`<script>alert(1)</script>after`, `after`},
+		{`<SCRIPT>alert(1)</SCRIPT>after`, `after`},
+		{`<style>body { display: none }</style>after`, `after`},
+		{`<iframe src="https://example.com/"></iframe>`, ``},
+		{`<noscript><p>x</p></noscript>`, ``},
+		{`<div><script><script>x</script></script></div>`, `<div></div>`},
+		{`<form action="/"><input name="q"></form>`, ``},
+		{`<blink>text</blink>`, `text`},
+
+		{`<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{`<img src="x.png" onerror="alert(1)">`, `<img src="x.png">`},
+		{`<div style="position:fixed;top:0;left:0">x</div>`, `<div>x</div>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href=" JavaScript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		{`<img src="data:image/svg+xml,<svg onload=alert(1)>">`, `<img>`},
+		{`<a href="https://example.com/" title="t">x</a>`, `<a href="https://example.com/" title="t">x</a>`},
+		{`<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com">x</a>`},
+		{`<svg><a xlink:href="javascript:alert(1)">x</a></svg>`, `<a>x</a>`},
+	}
+
+	for _, test := range tests {
+		var out strings.Builder
+		if err := sanitizeHTML(&out, strings.NewReader(test.in)); err != nil {
+			t.Errorf("sanitizeHTML(%q): %v", test.in, err)
+			continue
+		}
+		if out.String() != test.out {
+			t.Errorf("sanitizeHTML(%q) = %q, want %q", test.in, out.String(), test.out)
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		safe bool
+	}{
+		{"", true},
+		{"image.png", true},
+		{"/res/view/image.png", true},
+		{"//example.com/", true},
+		{"#slide-2", true},
+		{"http://example.com/", true},
+		{"HTTPS://example.com/", true},
+		{"mailto:a@example.com", true},
+
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{"  javascript:alert(1)", false},
+		{"java\tscript:alert(1)", false},
+		{"vbscript:msgbox(1)", false},
+		{"data:text/html,<script>alert(1)</script>", false},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com/", false},
+		{"%zz", false},
+	}
+
+	for _, test := range tests {
+		if got := safeURL(test.url); got != test.safe {
+			t.Errorf("safeURL(%q) = %v, want %v", test.url, got, test.safe)
+		}
+	}
+}
+
+func TestCheckDeckHTMLLinks(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		problems deckProblems
+	}{
+		{map[string]string{"main.slide": "T\n\n* S\n\n[[https://example.com/][x]]\n.link mailto:a@example.com\n"}, nil},
+		{map[string]string{"main.slide": "T\n\n* S\n\n[[javascript:alert(1)][x]] [[ data:text/html,x]]\n.iframe vbscript:x 10 10\n"},
+			deckProblems{"main.slide:5: unsafe link scheme: javascript", "main.slide:5: unsafe link scheme: data",
+				"main.slide:6: unsafe link scheme: vbscript"}},
+		{map[string]string{"main.md": "# T\n\n[x](https://example.com/)\n\n[y](JavaScript:alert(1))\n"},
+			deckProblems{"main.md:5: unsafe link scheme: JavaScript"}},
+	}
+
+	defer func(mode string) { *htmlMode = mode }(*htmlMode)
+	*htmlMode = htmlSanitize
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		writeFiles(t, dir, test.files)
+
+		err := checkDeckHTML(dir)
+		if test.problems == nil && err != nil || test.problems != nil && !reflect.DeepEqual(err, test.problems) {
+			t.Errorf("checkDeckHTML(%v) = %v, want %v", test.files, err, test.problems)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// securityHeaders sets headers that protect every response. Handlers may
+// replace the Content-Security-Policy, e.g. to allow a deck's iframes.
+func securityHeaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+		// URLs carry presenter IDs, which must not leak to linked sites.
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Content-Security-Policy", contentSecurityPolicy(r, nil))
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// contentSecurityPolicy only allows scripts served by the app itself, so that
+// scripts in raw HTML or resources of uploaded decks never run. Frames may be
+// loaded from the app and from frameOrigins.
+func contentSecurityPolicy(r *http.Request, frameOrigins []string) string {
+	directives := []string{
+		"default-src 'self'",
+		"script-src " + requestOrigin(r) + *pathPrefix + "/static/",
+		"style-src 'self' 'unsafe-inline'",
+		"font-src 'self'",
+		"img-src 'self' data:",
+		"connect-src 'self'",
+		"frame-src " + strings.Join(append([]string{"'self'"}, frameOrigins...), " "),
+		"object-src 'none'",
+		"base-uri 'none'",
+		"form-action 'self'",
+		"frame-ancestors 'self'",
+	}
+
+	return strings.Join(directives, "; ")
+}
+
+// requestOrigin is the origin the request was made to. Unlike externalURL, it
+// ignores -b and X-Forwarded-Host, so scripts are only allowed from the host
+// that served the page.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if fromTrustedProxy(r) && firstHeaderValue(r, "X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
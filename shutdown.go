@@ -0,0 +1,56 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+const restartingMsg = "restarting"
+
+var shuttingDown int32
+
+func isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) != 0
+}
+
+// awaitShutdown blocks until the process is asked to terminate, then notifies
+// every connected presenter and viewer, flushes the index and shuts the servers
+// down. done is closed once the shutdown has completed.
+func awaitShutdown(done chan<- struct{}, servers ...*http.Server) {
+	defer close(done)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	signal.Stop(sig)
+
+	atomic.StoreInt32(&shuttingDown, 1)
+	slog.Info("shutting down")
+
+	registry.broadcast(restartingMsg)
+
+	if err := index.save(filepath.Join(*slidesDir, "index.json")); err != nil {
+		slog.Error("failed to save index", "err", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("failed to shutdown cleanly", "addr", server.Addr, "err", err)
+		}
+	}
+
+	registry.closeAll()
+}
@@ -5,62 +5,69 @@
 package main
 
 import (
-	"html/template"
-	"io"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
-
-	"code.google.com/p/go.tools/present"
+	"time"
 )
 
 func presentSlide(w http.ResponseWriter, r *http.Request) {
-	slideIdParam := strings.SplitN(r.URL.Path, "/", 2)[1]
+	// Decks are served at /{id}, their handouts at /{id}/print and PDF
+	// exports at /{id}/pdf.
+	parts := strings.Split(r.URL.Path[1:], "/")
+	view := ""
+	if len(parts) == 2 {
+		view = parts[1]
+	}
+
+	if len(parts) > 2 || view != "" && view != "print" && view != "pdf" {
+		http.NotFound(w, r)
+		return
+	}
+
+	slideIdParam := parts[0]
 	slideId := index.getSlideId(slideIdParam)
 	if slideId == "" {
 		http.NotFound(w, r)
 		return
 	}
 
-	slideFile := filepath.Join(*slidesDir, slideId, "main.slide")
-	f, err := os.Open(slideFile)
+	start := time.Now()
+	defer func() { renderSeconds.observe(time.Since(start)) }()
+
+	d, err := decks.deck(slideId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		internalError(w, slideId, "failed to load deck", err)
 		return
 	}
 
-	doc, err := present.Parse(f, slideFile, 0)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if view == "pdf" {
+		exportPDF(w, r, slideId, d)
 		return
 	}
 
-	tmpl := present.Template()
-	tmpl.Funcs(template.FuncMap{"playable": func(c present.Code) bool { return false },
-		"rSlideId": func() string {
-			return slideIdParam
-		},
-		"userRole": func() string {
-			if slideId == slideIdParam {
-				return "p"
-			} else {
-				return "v"
-			}
-		}})
-	_, err = tmpl.New("action").Parse(actionTmpl)
+	tmpl, err := decks.template(slideId, slideIdParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		internalError(w, slideId, "failed to prepare templates", err)
 		return
 	}
-	_, err = tmpl.New("slides").Parse(slidesTmpl)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy(r, d.meta.FrameOrigins))
+	if view == "print" {
+		// Only presenters may include their notes.
+		withNotes := slideId == slideIdParam && r.URL.Query().Get("notes") != ""
+		err = renderHandout(w, d, tmpl, withNotes)
+	} else {
+		err = d.doc.Render(w, tmpl)
 	}
 
-	if err := doc.Render(w, tmpl); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err != nil {
+		internalError(w, slideId, "failed to render deck", err)
 		return
 	}
 }
@@ -73,15 +80,33 @@ func slideResource(w http.ResponseWriter, r *http.Request) {
 	}
 
 	slideId := index.getSlideId(parts[2])
-	resFile := filepath.Join(*slidesDir, slideId, parts[3])
-	info, err := os.Stat(resFile)
+	if slideId == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	resFile, ok := deckFile(slideId, parts[3])
+	if !ok || isSlideSource(filepath.Join(*slidesDir, slideId), parts[3]) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(resFile)
 	if os.IsNotExist(err) {
 		http.NotFound(w, r)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		internalError(w, slideId, "failed to open resource", err)
+		return
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		internalError(w, slideId, "failed to read resource", err)
 		return
 	}
 
@@ -90,14 +115,63 @@ func slideResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f, err := os.Open(resFile)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	setCacheControl(w, r, deckVersion(slideId))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// internalError logs err and sends a generic error page. Errors about a deck
+// often name its directory, which is named after the presenter ID, so they
+// are never sent to clients.
+func internalError(w http.ResponseWriter, slideId, msg string, err error) {
+	slog.Error(msg, "err", redactId(err.Error(), slideId))
+	http.Error(w, "500 internal server error", http.StatusInternalServerError)
+}
+
+// isSlideSource reports whether name is the slide file of the deck in
+// deckDir or any other slide file. They hold speaker notes, so they are never
+// served.
+func isSlideSource(deckDir, name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".slide", ".md":
+		return true
 	}
+	return path.Clean(name) == deckSource(deckDir)
+}
 
-	defer f.Close()
-	io.Copy(w, f)
+// deckFile resolves name to a file inside the directory of the deck for
+// slideId. It refuses names that escape the directory or refer to dotfiles.
+func deckFile(slideId, name string) (string, bool) {
+	return confinedPath(filepath.Join(*slidesDir, slideId), name)
+}
+
+// confinedPath resolves the slash separated name relative to dir, refusing
+// names that escape dir or refer to dotfiles.
+func confinedPath(dir, name string) (string, bool) {
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" || strings.HasPrefix(elem, ".") || strings.Contains(elem, "\\") {
+			return "", false
+		}
+	}
+
+	file := filepath.Join(dir, filepath.FromSlash(name))
+
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+
+	return file, true
+}
+
+// deckVersion changes every time the deck for slideId is uploaded. It is
+// appended to resource URLs so they can be cached indefinitely.
+func deckVersion(slideId string) string {
+	info, err := os.Stat(filepath.Join(*slidesDir, slideId))
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(info.ModTime().UnixNano(), 36)
 }
 
 // Imported from https://code.google.com/p/go/source/browse/present/templates/slides.tmpl?repo=talks
@@ -110,17 +184,17 @@ const slidesTmpl = `
   <head>
     <title>{{.Title}}</title>
     <meta charset='utf-8'>
-	<script>
-	var rSlideId="{{rSlideId}}";
-	var userRole="{{userRole}}";
-	</script>
-    <script src='/static/slides.js'></script>
-    <script src='/static/remote.js'></script>
+    <meta name='rpresent' data-slide-id='{{rSlideId}}' data-role='{{userRole}}'
+          data-path-prefix='{{pathPrefix}}' data-static-version='{{staticVersion}}'
+          data-themes='{{range $i, $url := themeStyles}}{{if $i}} {{end}}{{$url}}{{end}}'>
+    <script src='{{pathPrefix}}/static/remote.js?v={{staticVersion}}'></script>
+    <script src='{{pathPrefix}}/static/slides.js?v={{staticVersion}}'></script>
+    {{with deckStyle}}<style>{{.}}</style>{{end}}
   </head>
 
   <body style='display: none'>
 
-    <section class='slides layout-widescreen'>
+    <section class='slides {{layoutClass}}'>
 
       <article>
 	  	{{if eq userRole "v"}}<a class="helpLink" href="help" target="_blank">Help</a>{{end}}
@@ -132,6 +206,7 @@ const slidesTmpl = `
             {{range .TextElem}}{{elem $.Template .}}{{end}}
           </div>
         {{end}}
+        {{if eq userRole "p"}}{{template "notes" .TitleNotes}}{{end}}
       </article>
 
   {{range $i, $s := .Sections}}
@@ -144,6 +219,7 @@ const slidesTmpl = `
       {{else}}
         <h2>{{$s.Title}}</h2>
       {{end}}
+      {{if eq userRole "p"}}{{template "notes" $s.Notes}}{{end}}
       </article>
   <!-- end of slide {{$i}} -->
   {{end}}{{/* of Slide block */}}
@@ -158,13 +234,34 @@ const slidesTmpl = `
         {{end}}
       </article>
 
+    </section>
+
+    <nav id="toc" hidden>
+      <div class="toc-panel">
+        <h2>Contents</h2>
+        <p class="toc-hint">Press P to stop following the presenter, then pick a slide.</p>
+        <ol>
+          {{range toc}}<li><a href="#{{.Slide}}" data-slide="{{.Slide}}">{{.Title}}</a></li>
+          {{end}}
+        </ol>
+      </div>
+    </nav>
+
   </body>
   {{if .PlayEnabled}}
-  <script src='/play.js'></script>
+  <script src='{{pathPrefix}}/static/play.js?v={{staticVersion}}'></script>
   {{end}}
+  {{if eq userRole "p"}}
+  <script src='{{pathPrefix}}/static/notes.js?v={{staticVersion}}'></script>
+  {{end}}
+  <script src='{{pathPrefix}}/static/toc.js?v={{staticVersion}}'></script>
 </html>
 {{end}}
 
+{{define "notes"}}
+{{with .}}<aside class="notes">{{range .}}<p>{{.}}</p>{{end}}</aside>{{end}}
+{{end}}
+
 {{define "newline"}}
 <br>
 {{end}}`
@@ -201,19 +298,19 @@ It determines how the formatting actions are rendered.
 {{end}}
 
 {{define "code"}}
-  <div class="code{{if playable .}} playground{{end}}" contenteditable="true" spellcheck="false">{{.Text}}</div>
+  <div class="code{{if playable .}} playground{{end}}"{{if .Edit}} contenteditable="true" spellcheck="false"{{end}}>{{.Text}}</div>
 {{end}}
 
 {{define "image"}}
 <div class="image">
-  <img src="res/{{rSlideId}}/{{.URL}}"{{with .Height}} height="{{.}}"{{end}}{{with .Width}} width="{{.}}"{{end}}>
+  <img src="{{resURL .URL}}"{{with .Height}} height="{{.}}"{{end}}{{with .Width}} width="{{.}}"{{end}}>
 </div>
 {{end}}
 
 {{define "iframe"}}
-<iframe src="{{.URL}}"{{with .Height}} height="{{.}}"{{end}}{{with .Width}} width="{{.}}"{{end}}></iframe>
+<iframe src="{{resURL .URL}}"{{with .Height}} height="{{.}}"{{end}}{{with .Width}} width="{{.}}"{{end}}></iframe>
 {{end}}
 
-{{define "link"}}<p class="link"><a href="{{.URL}}" target="_blank">{{style .Label}}</a></p>{{end}}
+{{define "link"}}<p class="link"><a href="{{resURL .URL.String}}" target="_blank">{{style .Label}}</a></p>{{end}}
 
 {{define "html"}}{{.HTML}}{{end}}`
@@ -0,0 +1,139 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSlideResource(t *testing.T) {
+	dir := useSlidesDir(t)
+	slideId, viewId := "restslid", "restview"
+	index.addSlide(slideId, viewId)
+
+	writeFiles(t, dir, map[string]string{
+		"index.json":                   "{}",
+		"otherdck/secret.png":          "other deck",
+		slideId + "/main.slide":        "Title\n\n* Slide\n\n: note\n",
+		slideId + "/" + deckEntryFile:  "main.slide\n",
+		slideId + "/a.png":             "image",
+		slideId + "/img/b.png":         "nested image",
+		slideId + "/.hidden":           "dotfile",
+		slideId + "/img/.DS_Store":     "dotfile",
+		slideId + "/talks/other.slide": "Other\n",
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/res/" + viewId + "/a.png", http.StatusOK},
+		{"/res/" + slideId + "/a.png", http.StatusOK},
+		{"/res/" + viewId + "/img/b.png", http.StatusOK},
+		{"/res/" + viewId + "/img%2Fb.png", http.StatusOK},
+
+		{"/res/unknown1/a.png", http.StatusNotFound},
+		{"/res//index.json", http.StatusNotFound},
+		{"/res/" + viewId, http.StatusNotFound},
+		{"/res/" + viewId + "/missing.png", http.StatusNotFound},
+
+		{"/res/" + viewId + "/../index.json", http.StatusNotFound},
+		{"/res/" + viewId + "/../otherdck/secret.png", http.StatusNotFound},
+		{"/res/" + viewId + "/img/../../index.json", http.StatusNotFound},
+		{"/res/" + viewId + "/%2e%2e/index.json", http.StatusNotFound},
+		{"/res/" + viewId + "/%2E%2E%2Findex.json", http.StatusNotFound},
+		{"/res/" + viewId + "/img%2F..%2F..%2Findex.json", http.StatusNotFound},
+		{"/res/" + viewId + "/..%5Cindex.json", http.StatusNotFound},
+		{"/res/" + viewId + "/img%5Cb.png", http.StatusNotFound},
+		{"/res/" + viewId + "//etc/passwd", http.StatusNotFound},
+
+		{"/res/" + viewId + "/.hidden", http.StatusNotFound},
+		{"/res/" + viewId + "/" + deckEntryFile, http.StatusNotFound},
+		{"/res/" + viewId + "/img/.DS_Store", http.StatusNotFound},
+
+		{"/res/" + viewId + "/main.slide", http.StatusNotFound},
+		{"/res/" + viewId + "/talks/other.slide", http.StatusNotFound},
+
+		{"/res/" + viewId + "/img", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		slideResource(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("GET %s: got status %d, want %d", test.path, w.Code, test.status)
+		}
+	}
+}
+
+func TestDeckFile(t *testing.T) {
+	dir := useSlidesDir(t)
+	deckDir := filepath.Join(dir, "deckslid")
+
+	tests := []struct {
+		name string
+		file string
+		ok   bool
+	}{
+		{"a.png", filepath.Join(deckDir, "a.png"), true},
+		{"img/b.png", filepath.Join(deckDir, "img", "b.png"), true},
+		{"img/sub/c.png", filepath.Join(deckDir, "img", "sub", "c.png"), true},
+
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"../index.json", "", false},
+		{"img/../../index.json", "", false},
+		{"img/../a.png", "", false},
+		{"./a.png", "", false},
+		{"/etc/passwd", "", false},
+		{"img//b.png", "", false},
+		{"img/", "", false},
+		{`..\index.json`, "", false},
+		{`img\b.png`, "", false},
+		{".hidden", "", false},
+		{"img/.DS_Store", "", false},
+		{".git/config", "", false},
+	}
+
+	for _, test := range tests {
+		file, ok := deckFile("deckslid", test.name)
+		if file != test.file || ok != test.ok {
+			t.Errorf("deckFile(%q) = %q, %v; want %q, %v", test.name, file, ok, test.file, test.ok)
+		}
+	}
+}
+
+func TestConfinedPath(t *testing.T) {
+	dir := filepath.Join("slides", "deck")
+
+	tests := []struct {
+		name string
+		file string
+		ok   bool
+	}{
+		{"a.png", filepath.Join(dir, "a.png"), true},
+		{"img/b.png", filepath.Join(dir, "img", "b.png"), true},
+
+		{"", "", false},
+		{"..", "", false},
+		{"../index.json", "", false},
+		{"img/../../index.json", "", false},
+		{"/etc/passwd", "", false},
+		{`..\index.json`, "", false},
+		{".hidden", "", false},
+		{"img/.DS_Store", "", false},
+	}
+
+	for _, test := range tests {
+		file, ok := confinedPath(dir, test.name)
+		if file != test.file || ok != test.ok {
+			t.Errorf("confinedPath(%q, %q) = %q, %v; want %q, %v", dir, test.name, file, ok, test.file, test.ok)
+		}
+	}
+}
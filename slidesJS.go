@@ -6,7 +6,9 @@ const slidesJS = `
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-var PERMANENT_URL_PREFIX = '/static/';
+var PERMANENT_URL_PREFIX = (typeof pathPrefix !== 'undefined' ? pathPrefix : '') + '/static/';
+
+var STATIC_QUERY = typeof staticVersion !== 'undefined' ? '?v=' + staticVersion : '';
 
 var SLIDE_CLASSES = ['far-past', 'past', 'current', 'next', 'far-next'];
 
@@ -432,23 +434,24 @@ function addEventListeners() {
 
 /* Initialization */
 
-function addFontStyle() {
-  var el = document.createElement('link');
-  el.rel = 'stylesheet';
-  el.type = 'text/css';
-  el.href = '//fonts.googleapis.com/css?family=' +
-            'Open+Sans:regular,semibold,italic,italicsemibold|Droid+Sans+Mono';
-
-  document.body.appendChild(el);
-};
-
 function addGeneralStyle() {
   var el = document.createElement('link');
   el.rel = 'stylesheet';
   el.type = 'text/css';
-  el.href = PERMANENT_URL_PREFIX + 'styles.css';
+  el.href = PERMANENT_URL_PREFIX + 'styles.css' + STATIC_QUERY;
   document.body.appendChild(el);
 
+  if (typeof themeURLs !== 'undefined' && themeURLs) {
+    var urls = themeURLs.split(' ');
+    for (var i = 0; i < urls.length; i++) {
+      var el = document.createElement('link');
+      el.rel = 'stylesheet';
+      el.type = 'text/css';
+      el.href = urls[i];
+      document.body.appendChild(el);
+    }
+  }
+
   var el = document.createElement('meta');
   el.name = 'viewport';
   el.content = 'width=1100,height=750';
@@ -465,7 +468,7 @@ function addPrintStyle() {
   el.rel = 'stylesheet';
   el.type = 'text/css';
   el.media = "print";
-  el.href = PERMANENT_URL_PREFIX + 'print.css';
+  el.href = PERMANENT_URL_PREFIX + 'print.css' + STATIC_QUERY;
   document.body.appendChild(el);
 };
 
@@ -474,7 +477,6 @@ function handleDomLoaded() {
 
   setupFrames();
 
-  addFontStyle();
   addGeneralStyle();
   addPrintStyle();
   addEventListeners();
@@ -2,6 +2,33 @@ package main
 
 // Imported from https://code.google.com/p/go/source/browse/present/static/styles.css?repo=talks
 const stylesCSS = `
+/* Fonts */
+
+@font-face {
+  font-family: 'Open Sans';
+  src: url('fonts/open-sans-regular.woff2') format('woff2');
+}
+@font-face {
+  font-family: 'Open Sans';
+  font-style: italic;
+  src: url('fonts/open-sans-italic.woff2') format('woff2');
+}
+@font-face {
+  font-family: 'Open Sans';
+  font-weight: 600;
+  src: url('fonts/open-sans-semibold.woff2') format('woff2');
+}
+@font-face {
+  font-family: 'Open Sans';
+  font-weight: 600;
+  font-style: italic;
+  src: url('fonts/open-sans-semibold-italic.woff2') format('woff2');
+}
+@font-face {
+  font-family: 'Source Code Pro';
+  src: url('fonts/source-code-pro-regular.woff2') format('woff2');
+}
+
 /* Framework */
 
 html {
@@ -331,7 +358,7 @@ pre {
   margin: 0;
   padding: 0;
 
-  font-family: 'Droid Sans Mono', 'Courier New', monospace;
+  font-family: 'Source Code Pro', 'Courier New', monospace;
   font-size: 18px;
   line-height: 24px;
   letter-spacing: -1px;
@@ -341,7 +368,7 @@ pre {
 
 code {
   font-size: 95%;
-  font-family: 'Droid Sans Mono', 'Courier New', monospace;
+  font-family: 'Source Code Pro', 'Courier New', monospace;
 
   color: black;
 }
@@ -375,6 +402,15 @@ p.link {
 div.code {
   outline: 0px solid transparent;
 }
+pre.numbers span:before {
+  content: attr(num);
+  margin-right: 1em;
+  display: inline-block;
+  color: rgb(150, 150, 150);
+}
+div.code b {
+  background: rgb(255, 255, 180);
+}
 div.playground {
   position: relative;
 }
@@ -437,6 +473,134 @@ div.output .buttons {
 	line-height: 1.2em;
 }
 
+/* Presenter notes and window */
+.slides > article > aside.notes {
+  display: none;
+}
+body.presenter-view {
+  background: rgb(34, 34, 34);
+}
+body.presenter-view .slides > article.far-past,
+body.presenter-view .slides > article.past,
+body.presenter-view .slides > article.far-next {
+  display: none;
+}
+body.presenter-view .slides > article.current,
+body.presenter-view .slides > article.next {
+  display: block;
+  left: 20px;
+  top: 60px;
+  margin: 0;
+  transform-origin: 0 0;
+  -o-transform-origin: 0 0;
+  -moz-transform-origin: 0 0;
+  -webkit-transform-origin: 0 0;
+  transition: none;
+  -o-transition: none;
+  -moz-transition: none;
+  -webkit-transition: none;
+}
+body.presenter-view .slides > article.current {
+  transform: scale(.55);
+  -o-transform: scale(.55);
+  -moz-transform: scale(.55);
+  -webkit-transform: scale(.55);
+}
+body.presenter-view .slides > article.next {
+  left: 660px;
+  transform: scale(.4);
+  -o-transform: scale(.4);
+  -moz-transform: scale(.4);
+  -webkit-transform: scale(.4);
+  opacity: .8;
+}
+body.presenter-view .slide-area {
+  display: none;
+}
+#presenter-timer {
+  position: fixed;
+  top: 12px;
+  left: 20px;
+  color: white;
+  font-family: 'Source Code Pro', 'Courier New', monospace;
+  font-size: 28px;
+  cursor: pointer;
+}
+#presenter-notes {
+  position: fixed;
+  top: 470px;
+  left: 20px;
+  right: 20px;
+  bottom: 20px;
+  overflow: auto;
+  color: white;
+  font-family: 'Open Sans', Arial, sans-serif;
+  font-size: 24px;
+  line-height: 1.4em;
+}
+
+#toc {
+  position: fixed;
+  top: 0;
+  left: 0;
+  right: 0;
+  bottom: 0;
+  z-index: 1000;
+  background: rgba(0, 0, 0, .5);
+}
+#toc[hidden] {
+  display: none;
+}
+#toc .toc-panel {
+  position: absolute;
+  top: 40px;
+  bottom: 40px;
+  left: 50%;
+  width: 600px;
+  margin-left: -330px;
+  padding: 20px 30px;
+  overflow: auto;
+  border-radius: 10px;
+  background: white;
+  color: rgb(51, 51, 51);
+  font-family: 'Open Sans', Arial, sans-serif;
+  font-size: 20px;
+  line-height: 1.6em;
+}
+#toc h2 {
+  margin: 0 0 10px;
+  font-size: 30px;
+}
+#toc ol {
+  margin: 0;
+  padding-left: 40px;
+}
+#toc a {
+  color: rgb(51, 51, 51);
+  text-decoration: none;
+}
+#toc a:hover {
+  color: rgb(0, 102, 204);
+}
+#toc a.current {
+  font-weight: 600;
+}
+#toc .toc-hint {
+  display: none;
+  margin: 0 0 10px;
+  font-size: 16px;
+  color: rgb(102, 102, 102);
+}
+#toc.locked .toc-hint {
+  display: block;
+}
+#toc.locked a {
+  cursor: default;
+}
+#toc.locked a:hover {
+  color: rgb(51, 51, 51);
+}
+
 /* Output resize details */
 .ui-resizable-handle {
   position: absolute;
@@ -0,0 +1,96 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+// builtinThemes maps the theme names decks can pick in deck.json to their
+// stylesheets, which are layered over styles.css.
+var builtinThemes = map[string]string{
+	"dark":      darkThemeCSS,
+	"solarized": solarizedThemeCSS,
+}
+
+const darkThemeCSS = `
+body {
+  background: rgb(24, 24, 24);
+}
+
+.slides > article {
+  background-color: rgb(40, 44, 52);
+  border-color: rgba(255, 255, 255, .2);
+
+  color: rgb(220, 223, 228);
+  text-shadow: none;
+}
+
+h1, h2, h3 {
+  color: rgb(240, 240, 240);
+}
+
+a {
+  color: rgb(97, 175, 239);
+}
+a:visited {
+  color: rgba(97, 175, 239, .75);
+}
+a:hover {
+  color: white;
+}
+
+div.code {
+  background: rgb(30, 33, 39);
+  border-color: rgb(60, 64, 72);
+}
+pre, code {
+  color: rgb(220, 223, 228);
+}
+div.code b {
+  background: rgb(92, 80, 30);
+}
+
+td, th {
+  border-color: rgb(60, 64, 72);
+}`
+
+const solarizedThemeCSS = `
+body {
+  background: rgb(238, 232, 213);
+}
+
+.slides > article {
+  background-color: rgb(253, 246, 227);
+  border-color: rgb(147, 161, 161);
+
+  color: rgb(101, 123, 131);
+  text-shadow: none;
+}
+
+h1, h2, h3 {
+  color: rgb(7, 54, 66);
+}
+
+a {
+  color: rgb(38, 139, 210);
+}
+a:visited {
+  color: rgba(38, 139, 210, .75);
+}
+a:hover {
+  color: rgb(211, 54, 130);
+}
+
+div.code {
+  background: rgb(238, 232, 213);
+  border-color: rgb(220, 213, 190);
+}
+pre, code {
+  color: rgb(88, 110, 117);
+}
+div.code b {
+  background: rgb(250, 228, 160);
+}
+
+td, th {
+  border-color: rgb(220, 213, 190);
+}`
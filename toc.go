@@ -0,0 +1,31 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tocEntry is a slide listed in the table of contents of a deck. Slide is
+// the slide number used in URL fragments, where the title slide is 1.
+type tocEntry struct {
+	Slide int
+	Title string
+}
+
+// toc lists the title slide and every section of the deck. Untitled sections,
+// such as pages of imported PDF documents, are named by their number.
+func (d *deck) toc() []tocEntry {
+	entries := []tocEntry{{Slide: 1, Title: strings.TrimSpace(d.doc.Title)}}
+	for i, s := range d.doc.Sections {
+		title := strings.TrimSpace(s.Title)
+		if title == "" {
+			title = fmt.Sprintf("Slide %d", i+2)
+		}
+		entries = append(entries, tocEntry{Slide: i + 2, Title: title})
+	}
+	return entries
+}
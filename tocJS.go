@@ -0,0 +1,79 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+const tocJS = `
+document.addEventListener("DOMContentLoaded", function() {
+  var toc = document.getElementById("toc");
+  if(!toc) {
+    return;
+  }
+
+  toc.addEventListener("click", function(event) {
+    var link = event.target.closest("a[data-slide]");
+    if(!link) {
+      if(event.target == toc) {
+        hideTOC();
+      }
+      return;
+    }
+
+    event.preventDefault();
+    jumpToSlide(Number(link.getAttribute("data-slide")) - 1);
+  }, false);
+
+  document.addEventListener("keypress", function(event) {
+    if(event.target.classList.contains("code")) {
+      return;
+    }
+
+    if(event.charCode == 84 || event.charCode == 116) {
+      toc.hidden ? showTOC() : hideTOC();
+    }
+  }, false);
+
+  document.addEventListener("keydown", function(event) {
+    if(event.keyCode == 27 && !toc.hidden) {
+      hideTOC();
+    }
+  }, false);
+});
+
+function showTOC() {
+  var toc = document.getElementById("toc");
+
+  // Viewers following the presenter would be taken back at the next slide
+  // change, so they may only jump once they have paused the remote.
+  toc.classList.toggle("locked", userRole == "v" && !remotePaused);
+
+  var links = toc.querySelectorAll("a[data-slide]");
+  for(var i = 0; i < links.length; i++) {
+    var current = Number(links[i].getAttribute("data-slide")) == curSlide + 1;
+    links[i].classList.toggle("current", current);
+    if(current) {
+      links[i].scrollIntoView({block: "nearest"});
+    }
+  }
+
+  toc.hidden = false;
+}
+
+function hideTOC() {
+  document.getElementById("toc").hidden = true;
+}
+
+// jumpToSlide shows slide no, counted from 0. For presenters, updateSlides
+// sends the jump to every viewer through sendRemote.
+function jumpToSlide(no) {
+  if(userRole == "v" && !remotePaused) {
+    return;
+  }
+
+  if(no >= 0 && no < slideEls.length) {
+    curSlide = no;
+    updateSlides();
+  }
+  hideTOC();
+}`
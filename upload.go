@@ -8,12 +8,14 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -22,18 +24,21 @@ import (
 const keyChars = "abcdefghijklmnopqrstuvwxyz0123456789"
 
 var (
-	errNoSlide       = errors.New("Archive must contain at least one .slide file")
-	errTooManySlides = errors.New("Archive must contain only one .slide file")
+	errNoSlide       = errors.New("Archive must contain a .slide file, " + markdownFile + " or a PDF document")
+	errTooManySlides = errors.New("Archive contains several slide files; name the one to present as the entry point or as main in " + deckMetaFile)
 )
 
 func processUpload(w http.ResponseWriter, r *http.Request) {
 	slideId, viewId := index.getIdPair(r.FormValue("existingId"))
-	if slideId == "" || viewId == "" {
+	update := slideId != "" && viewId != ""
+	if !update {
 		slideId, viewId = generateKey(), generateKey()
 	}
 
-	file, _, err := r.FormFile("slideArchive")
+	file, header, err := r.FormFile("slideArchive")
 	if err == http.ErrMissingFile {
+		slog.Warn("upload rejected", "view", viewId, "reason", "missing file")
+		uploadsTotal.inc("missing_file")
 		w.WriteHeader(http.StatusBadRequest)
 		msgTmpl.Execute(w, map[string]interface{}{
 			"title": "Missing File",
@@ -44,38 +49,83 @@ func processUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		uploadsTotal.inc("error")
+		internalError(w, slideId, "upload failed", err)
 		return
 	}
 
 	defer file.Close()
 
-	if err := extractArchive(filepath.Join(*slidesDir, slideId), file); err != nil {
+	// Uploads are extracted and validated in a directory of their own, so
+	// that a rejected update leaves the deck it was meant to replace intact.
+	uploadDir, err := os.MkdirTemp(*slidesDir, ".upload-")
+	if err != nil {
+		uploadsTotal.inc("error")
+		internalError(w, slideId, "upload failed", err)
+		return
+	}
+
+	defer os.RemoveAll(uploadDir)
+
+	var warnings []string
+	if isPDF(file) {
+		warnings, err = extractPDF(uploadDir, header.Filename, file)
+	} else {
+		warnings, err = extractArchive(uploadDir, r.FormValue("entryPoint"), file)
+	}
+
+	if err == nil {
+		err = replaceDeck(slideId, uploadDir)
+	}
 
+	if err != nil {
+
+		problems, hasProblems := err.(deckProblems)
 		badFile := err == gzip.ErrChecksum || err == gzip.ErrHeader || err == tar.ErrHeader
-		badContent := err == errNoSlide || err == errTooManySlides
+		badContent := err == errNoSlide || err == errTooManySlides || hasProblems
 
 		if badFile || badContent {
-			w.WriteHeader(http.StatusBadRequest)
-			msgTmpl.Execute(w, map[string]interface{}{
+			slog.Warn("upload rejected", "view", viewId, "reason", err)
+			if badFile {
+				uploadsTotal.inc("bad_file")
+			} else {
+				uploadsTotal.inc("bad_content")
+			}
+
+			msg := map[string]interface{}{
 				"title": "Bad Upload",
 				"msg":   err.Error(),
 				"error": true,
-			})
+			}
+			if hasProblems {
+				msg["msg"] = "Slide archive contains errors"
+				msg["details"] = []string(problems)
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			msgTmpl.Execute(w, msg)
 			return
 		}
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		uploadsTotal.inc("error")
+		internalError(w, slideId, "upload failed", err)
 		return
 	}
 
+	decks.invalidate(slideId)
 	index.addSlide(slideId, viewId)
-	index.save(filepath.Join(*slidesDir, "index.json"))
+	if err := index.save(filepath.Join(*slidesDir, "index.json")); err != nil {
+		slog.Error("failed to save index", "err", err)
+	}
 
-	shareTmpl.Execute(w, map[string]string{
-		"slideId": slideId,
-		"viewId":  viewId,
-		"baseURL": *baseURL,
+	slog.Info("upload succeeded", "view", viewId, "update", update)
+	uploadsTotal.inc("success")
+
+	shareTmpl.Execute(w, map[string]interface{}{
+		"slideId":  slideId,
+		"viewId":   viewId,
+		"baseURL":  externalURL(r),
+		"warnings": warnings,
 	})
 
 	return
@@ -90,24 +140,18 @@ func generateKey() string {
 	return string(buf)
 }
 
-func extractArchive(slideBase string, file multipart.File) (err error) {
-	if err := os.RemoveAll(slideBase); err != nil {
-		return err
-	}
-
-	if err := os.MkdirAll(slideBase, 0700); err != nil {
-		return err
-	}
-
-	defer cleanupOnFailure(slideBase, &err)
-
+// extractArchive extracts the slide archive in file to the empty directory
+// slideBase. The deck presented is the slide file named by entry, by the main
+// entry of deck.json or, failing those, the only slide file in the archive. A
+// PDF document is imported when the archive has no slide file.
+func extractArchive(slideBase, entry string, file multipart.File) (warnings []string, err error) {
 	gzipReader, err := gzip.NewReader(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer gzipReader.Close()
 
-	var slideCount int
+	var sources, pdfs []string
 	reader := tar.NewReader(gzipReader)
 	for {
 		header, err := reader.Next()
@@ -116,54 +160,122 @@ func extractArchive(slideBase string, file multipart.File) (err error) {
 		}
 
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		name := path.Clean(header.Name)
+		if name == "." {
+			continue
+		}
+
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, deckProblems{fmt.Sprintf("%s: file must be inside the archive", header.Name)}
+		}
+
+		// Dotfiles, such as those added by some archivers, are never served.
+		fileName, ok := confinedPath(slideBase, name)
+		if !ok {
+			continue
 		}
 
-		fileName := filepath.Join(slideBase, header.Name)
 		if header.FileInfo().IsDir() {
 			if err := os.MkdirAll(fileName, 0700); err != nil {
-				return err
+				return nil, err
 			}
 
 			continue
 		}
 
-		if strings.HasSuffix(fileName, ".slide") {
-			fileName = filepath.Join(filepath.Dir(fileName), "main.slide")
-			slideCount++
+		switch {
+		case strings.HasSuffix(name, ".slide"), path.Base(name) == markdownFile:
+			sources = append(sources, name)
+		case strings.EqualFold(path.Ext(name), ".pdf"):
+			pdfs = append(pdfs, name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+			return nil, err
 		}
 
 		f, err := os.Create(fileName)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		defer f.Close()
 
 		if _, err := io.Copy(f, reader); err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	if entry == "" {
+		meta, err := loadDeckMeta(filepath.Join(slideBase, deckMetaFile))
+		if err != nil {
+			return nil, deckProblems{err.Error()}
 		}
+		entry = meta.Main
 	}
 
-	if slideCount == 0 {
-		return errNoSlide
+	switch {
+	case entry != "":
+		entry = path.Clean(entry)
+		if err := checkEntry(slideBase, entry); err != nil {
+			return nil, deckProblems{err.Error()}
+		}
+	case len(sources) == 1:
+		entry = sources[0]
+	case len(sources) > 1:
+		return nil, errTooManySlides
+	case len(pdfs) == 1:
+		entry = pdfs[0]
+	default:
+		return nil, errNoSlide
 	}
 
-	if slideCount > 1 {
-		return errTooManySlides
+	if strings.EqualFold(path.Ext(entry), ".pdf") {
+		if entry, err = importPDF(slideBase, entry); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	if err := writeDeckEntry(slideBase, entry); err != nil {
+		return nil, err
+	}
+
+	if err := checkDeckHTML(slideBase); err != nil {
+		return nil, err
+	}
+
+	return validateDeck(slideBase)
 }
 
-func cleanupOnFailure(slideBaseDir string, failure *error) {
-	if *failure == nil {
-		return
+// writeDeckEntry records entry as the slide file of the deck in deckDir.
+func writeDeckEntry(deckDir, entry string) error {
+	return os.WriteFile(filepath.Join(deckDir, deckEntryFile), []byte(entry+"\n"), 0600)
+}
+
+// replaceDeck moves the deck extracted to uploadDir in place of the deck of
+// slideId, if any.
+func replaceDeck(slideId, uploadDir string) error {
+	deckDir := filepath.Join(*slidesDir, slideId)
+	oldDir := uploadDir + ".old"
+	if err := os.Rename(deckDir, oldDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(uploadDir, deckDir); err != nil {
+		if err := os.Rename(oldDir, deckDir); err != nil && !os.IsNotExist(err) {
+			slog.Error("failed to restore replaced deck", "err", redactId(err.Error(), slideId))
+		}
+		return err
 	}
 
-	if err := os.RemoveAll(slideBaseDir); err != nil {
-		log.Printf("Failed to cleanup directory: %s : %s\n", slideBaseDir, err)
+	if err := os.RemoveAll(oldDir); err != nil {
+		slog.Error("failed to remove replaced deck", "err", redactId(err.Error(), slideId))
 	}
+
+	return nil
 }
 
 var uploadTmpl = template.Must(template.New("upload").Parse(`
@@ -179,13 +291,16 @@ var uploadTmpl = template.Must(template.New("upload").Parse(`
 </head>
 <body>
 <h1>Add/Update Presentation</h1>
-<form action="/" method="POST" enctype="multipart/form-data">
-	<label for="slideArchive">Slide Archive (.tar.gz or .tgz):</label>
+<form action="{{.pathPrefix}}/" method="POST" enctype="multipart/form-data">
+	<label for="slideArchive">Slide Archive (.tar.gz or .tgz) or PDF:</label>
 	<input type="file" id="slideArchive" name="slideArchive">
 	<p>
 	<label for="existingId">Existing Slide ID:</label>
 	<input type="text" id="existingId" name="existingId">
 	<p>
+	<label for="entryPoint">Entry Point (for archives with several slide files):</label>
+	<input type="text" id="entryPoint" name="entryPoint" placeholder="talks/intro.slide">
+	<p>
 	<input type="submit" value="Upload">
 	<input type="reset" value="Reset">
 </form>
@@ -205,6 +320,12 @@ var msgTmpl = template.Must(template.New("message").Parse(`
 </head>
 <body>
 <h1{{if .error}} style="color: red"{{end}}>{{.msg}}</h1>
+{{with .details}}
+<ul>
+	{{range .}}<li>{{html .}}</li>
+	{{end}}
+</ul>
+{{end}}
 </body>
 </html>`))
 
@@ -227,12 +348,29 @@ var shareTmpl = template.Must(template.New("share").Parse(`
 	<input type="text" id="slideId" readonly="readonly" value="{{.slideId}}">
 	<p>
 	<label for="presenterURL">Presenter URL:</label>
-	<input type="text" id="presenterURL" readonly="readonly" value="{{.baseURL}}/{{.slideId}}">
+	<input type="text" id="presenterURL" readonly="readonly" autofocus value="{{.baseURL}}/{{.slideId}}">
 	<p>
 	<label for="presenterURL">View URL:</label>
 	<input type="text" id="presenterURL" readonly="readonly" value="{{.baseURL}}/{{.viewId}}">
-	<script>
-		document.getElementById("presenterURL").focus();
-	</script>
+	<p>
+	<label for="handoutURL">Handout URL:</label>
+	<input type="text" id="handoutURL" readonly="readonly" value="{{.baseURL}}/{{.viewId}}/print">
+	<p>
+	<label for="pdfURL">PDF URL:</label>
+	<input type="text" id="pdfURL" readonly="readonly" value="{{.baseURL}}/{{.viewId}}/pdf">
+	<p>
+	<label for="notesHandoutURL">Handout with Notes URL:</label>
+	<input type="text" id="notesHandoutURL" readonly="readonly" value="{{.baseURL}}/{{.slideId}}/print?notes=1">
+	<p>
+	Press N while presenting to open a presenter window with the current and next slides, speaker notes and a timer.
+	<p>
+	Press T to show the table of contents. Jumping to a slide from it takes every viewer along.
+	{{with .warnings}}
+	<h2>Warnings</h2>
+	<ul>
+		{{range .}}<li>{{html .}}</li>
+		{{end}}
+	</ul>
+	{{end}}
 </body>
 </html>`))
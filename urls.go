@@ -0,0 +1,105 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var trustedNets []*net.IPNet
+
+// parseTrustedProxies parses a comma separated list of IPs and CIDRs.
+func parseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address: %s", entry)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network: %s", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// normalizePrefix makes prefix start with a slash and strips any trailing one,
+// so that "", "/" and "talks/" become "", "" and "/talks".
+func normalizePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+func fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trustedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// externalURL returns the URL under which clients reach the app, including the
+// path prefix. The -b flag takes precedence over anything derived from r.
+func externalURL(r *http.Request) string {
+	if *baseURL != "" {
+		return strings.TrimSuffix(*baseURL, "/")
+	}
+
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if fromTrustedProxy(r) {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwdHost := firstHeaderValue(r, "X-Forwarded-Host"); fwdHost != "" {
+			host = fwdHost
+		}
+	}
+
+	return scheme + "://" + host + *pathPrefix
+}
+
+func firstHeaderValue(r *http.Request, name string) string {
+	value := strings.SplitN(r.Header.Get(name), ",", 2)[0]
+	return strings.TrimSpace(value)
+}
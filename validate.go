@@ -0,0 +1,197 @@
+// Copyright 2014, Chandra Sekar S.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the README.md file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// codeFilePattern extracts the file name from .code and .play commands.
+var codeFilePattern = regexp.MustCompile(`^\.(code|play)\s+(?:-(?:edit|numbers)\s+)*(\S+)`)
+
+// validateDeck checks that the files referred to by the slide file extracted
+// to deckDir are part of the archive and that it parses. Problems that would
+// break the deck are returned as deckProblems, others as warnings.
+func validateDeck(deckDir string) (warnings []string, err error) {
+	meta, err := loadDeckMeta(filepath.Join(deckDir, deckMetaFile))
+	if err != nil {
+		return nil, deckProblems{err.Error()}
+	}
+
+	var problems deckProblems
+	for _, font := range meta.Fonts {
+		file, ok := confinedPath(deckDir, font.Src)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: font file must be inside the archive: %s", deckMetaFile, font.Src))
+			continue
+		}
+
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%s: font file not found in archive: %s", deckMetaFile, font.Src))
+		}
+	}
+
+	source := deckSource(deckDir)
+	refs := checkSlideRefs
+	if isMarkdown(source) {
+		refs = checkMarkdownRefs
+	}
+
+	refWarnings, refProblems, err := refs(deckDir, source, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings = append(warnings, refWarnings...)
+	problems = append(problems, refProblems...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	if _, err := parseDeck(deckDir); err != nil {
+		return nil, deckProblems{err.Error()}
+	}
+
+	return warnings, nil
+}
+
+// checkSlideRefs checks the files referred to by commands in the slide file
+// source.
+func checkSlideRefs(deckDir, source string, meta deckMeta) (warnings, problems []string, err error) {
+	file, ok := confinedPath(deckDir, source)
+	if !ok {
+		return nil, []string{fmt.Sprintf("%s: file must be inside the archive", source)}, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+		args := strings.Fields(line)
+		if !strings.HasPrefix(line, ".") || len(args) < 2 {
+			continue
+		}
+
+		var name string
+		switch args[0] {
+		case ".image", ".iframe":
+			name = args[1]
+		case ".link":
+			// Only links to files in the deck are checked.
+			if u, err := url.Parse(args[1]); err != nil || !deckRelative(u) {
+				continue
+			}
+			name = args[1]
+		case ".code", ".play":
+			if m := codeFilePattern.FindStringSubmatch(line); m != nil {
+				name = m[2]
+			}
+		default:
+			continue
+		}
+
+		w, p := checkRef(deckDir, path.Dir(source), args[0], name, meta)
+		if w != "" {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %s", source, lineno, w))
+		}
+		if p != "" {
+			problems = append(problems, fmt.Sprintf("%s:%d: %s", source, lineno, p))
+		}
+	}
+
+	return warnings, problems, scanner.Err()
+}
+
+// checkMarkdownRefs checks the images referred to by the Markdown slide file
+// source.
+func checkMarkdownRefs(deckDir, source string, meta deckMeta) (warnings, problems []string, err error) {
+	file, ok := confinedPath(deckDir, source)
+	if !ok {
+		return nil, []string{fmt.Sprintf("%s: file must be inside the archive", source)}, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc := markdown.Parser().Parse(text.NewReader(content))
+	err = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, ok := n.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		lineno := markdownLine(content, n)
+		w, p := checkRef(deckDir, path.Dir(source), ".image", string(img.Destination), meta)
+		if w != "" {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %s", source, lineno, w))
+		}
+		if p != "" {
+			problems = append(problems, fmt.Sprintf("%s:%d: %s", source, lineno, p))
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return warnings, problems, err
+}
+
+// checkRef checks the file name that cmd refers to, relative to the
+// directory dir of the slide file. It returns a warning if the file is
+// external and won't work or a problem if it isn't in the archive.
+func checkRef(deckDir, dir, cmd, name string, meta deckMeta) (warning, problem string) {
+	if u, err := url.Parse(name); err == nil && u.IsAbs() {
+		return checkExternalRef(cmd, u, meta), ""
+	}
+
+	// Drop any query or fragment, e.g. of deck relative iframes.
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+
+	file, ok := confinedPath(deckDir, path.Join(dir, name))
+	if !ok {
+		return "", fmt.Sprintf("%s file must be inside the archive: %s", cmd, name)
+	}
+
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return "", fmt.Sprintf("%s file not found in archive: %s", cmd, name)
+	}
+
+	return "", ""
+}
+
+// checkExternalRef returns a warning if the cmd referring to u won't work.
+func checkExternalRef(cmd string, u *url.URL, meta deckMeta) string {
+	switch cmd {
+	case ".image":
+		return "images must be part of the archive: " + u.String()
+	case ".iframe":
+		origin := u.Scheme + "://" + u.Host
+		for _, allowed := range meta.FrameOrigins {
+			if strings.EqualFold(allowed, origin) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("iframe will be blocked unless %s is added to frameOrigins in %s", origin, deckMetaFile)
+	}
+	return ""
+}